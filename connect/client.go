@@ -1,28 +1,46 @@
 package connect
 
 import (
+	"SpringCache/logger"
 	pb "SpringCache/springcachepb"
 	"context"
 	"fmt"
-	"log"
+	"google.golang.org/grpc"
 	"time"
 )
 
 // client 包是去调用远端的方法，封装了用grpc调用远端节点的Get和Set 方法
 
 type Client struct {
-	Name string
-	Etcd *Etcd
+	Name   string
+	Etcd   *Etcd
+	Logger logger.Logger // 为空时不输出日志
+	// Interceptors 是每次调用远端节点时经过的一元拦截器，先出现的在外层
+	Interceptors []grpc.UnaryClientInterceptor
 }
 
 func newClient(name string, etcd *Etcd) *Client {
-	return &Client{name, etcd}
+	return &Client{Name: name, Etcd: etcd}
 }
 
-func (c *Client) Get(group string, key string) ([]byte, error) {
+func (c *Client) log() logger.Logger {
+	return logger.OrDiscard(c.Logger)
+}
+
+// dial 用etcd进行服务发现，获得带有拦截器的grpc连接
+func (c *Client) dial(ctx context.Context) (*grpc.ClientConn, error) {
+	if len(c.Interceptors) == 0 {
+		return DialPeerContext(ctx, c.Etcd.EtcdCli, c.Name)
+	}
+	return DialPeerContext(ctx, c.Etcd.EtcdCli, c.Name, grpc.WithChainUnaryInterceptor(c.Interceptors...))
+}
+
+// Get 向远端节点请求缓存值，返回的 GetResponse 中包含值和它的过期时间。
+// ctx 的超时和取消会一直传递到远端节点
+func (c *Client) Get(ctx context.Context, group string, key string) (*pb.GetResponse, error) {
 
 	// 用etcd进行服务发现, 获得grpc的连接
-	conn, err := DialPeer(c.Etcd.EtcdCli, c.Name)
+	conn, err := c.dial(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -30,23 +48,24 @@ func (c *Client) Get(group string, key string) ([]byte, error) {
 
 	// 创建grpc客户端，调用远程peer的get方法
 	grpcClient := pb.NewSpringCacheClient(conn)
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
 	defer cancel()
 	resp, err := grpcClient.Get(ctx, &pb.GetRequest{
 		Group: group,
 		Key:   key,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("could not get %s/%s from peer %s", group, key, c.Name)
+		return nil, fmt.Errorf("could not get %s/%s from peer %s: %w", group, key, c.Name, err)
 	}
-	log.Println("In client.Get, grpcClient.Get Done, resp :", resp)
-	return resp.GetValue(), nil
+	c.log().Debug("get from peer done", "peer", c.Name, "group", group, "key", key)
+	return resp, nil
 }
 
-func (c *Client) Set(group string, key string, value []byte, expire time.Time, ishot bool) error {
+// Set 让远端节点设置缓存，expire 为零值时表示永不过期，compressed 表示 value 是压缩后的字节
+func (c *Client) Set(ctx context.Context, group string, key string, value []byte, compressed bool, expire time.Time, ishot bool, tags []string) error {
 
 	// 用etcd进行服务发现, 获得grpc的连接
-	conn, err := DialPeer(c.Etcd.EtcdCli, c.Name)
+	conn, err := c.dial(ctx)
 	if err != nil {
 		return err
 	}
@@ -54,17 +73,23 @@ func (c *Client) Set(group string, key string, value []byte, expire time.Time, i
 
 	// 创建grpc客户端，调用远程peer的get方法
 	grpcClient := pb.NewSpringCacheClient(conn)
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
 	defer cancel()
+	var expireSec int64
+	if !expire.IsZero() {
+		expireSec = expire.Unix()
+	}
 	resp, err := grpcClient.Set(ctx, &pb.SetRequest{
-		Group:  group,
-		Key:    key,
-		Value:  value,
-		Expire: expire.Unix(),
-		Ishot:  ishot,
+		Group:      group,
+		Key:        key,
+		Value:      value,
+		Expire:     expireSec,
+		Ishot:      ishot,
+		Tags:       tags,
+		Compressed: compressed,
 	})
 	if err != nil {
-		log.Println("grpcClient.Set Error:", err)
+		c.log().Warn("set on peer failed", "peer", c.Name, "group", group, "key", key, "err", err)
 		return err
 	}
 	if !resp.GetOk() {
@@ -73,5 +98,105 @@ func (c *Client) Set(group string, key string, value []byte, expire time.Time, i
 	return nil
 }
 
+// Delete 让远端节点删除缓存中的键
+func (c *Client) Delete(ctx context.Context, group string, key string) error {
+
+	// 用etcd进行服务发现, 获得grpc的连接
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// 创建grpc客户端，调用远程peer的delete方法
+	grpcClient := pb.NewSpringCacheClient(conn)
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	resp, err := grpcClient.Delete(ctx, &pb.DeleteRequest{
+		Group: group,
+		Key:   key,
+	})
+	if err != nil {
+		return fmt.Errorf("could not delete %s/%s from peer %s: %w", group, key, c.Name, err)
+	}
+	if !resp.GetOk() {
+		return fmt.Errorf("grpcClient.Delete Failed !")
+	}
+	return nil
+}
+
+// GetMulti 在一次请求中向远端节点获取多个键，每个键的结果和错误分别放在返回的 MultiItem 中
+func (c *Client) GetMulti(ctx context.Context, group string, keys []string) ([]*pb.MultiItem, error) {
+
+	// 用etcd进行服务发现, 获得grpc的连接
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// 创建grpc客户端，调用远程peer的getmulti方法
+	grpcClient := pb.NewSpringCacheClient(conn)
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	resp, err := grpcClient.GetMulti(ctx, &pb.GetMultiRequest{
+		Group: group,
+		Keys:  keys,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not get %d keys of %s from peer %s: %w", len(keys), group, c.Name, err)
+	}
+	return resp.GetItems(), nil
+}
+
+// SetMulti 在一次请求中向远端节点设置多个键，返回设置失败的键和对应的错误
+func (c *Client) SetMulti(ctx context.Context, group string, items []*pb.MultiItem, ishot bool) ([]*pb.MultiItem, error) {
+
+	// 用etcd进行服务发现, 获得grpc的连接
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// 创建grpc客户端，调用远程peer的setmulti方法
+	grpcClient := pb.NewSpringCacheClient(conn)
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	resp, err := grpcClient.SetMulti(ctx, &pb.SetMultiRequest{
+		Group: group,
+		Items: items,
+		Ishot: ishot,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not set %d keys of %s on peer %s: %w", len(items), group, c.Name, err)
+	}
+	return resp.GetItems(), nil
+}
+
+// InvalidateTag 让远端节点删除带有 tag 的所有键
+func (c *Client) InvalidateTag(ctx context.Context, group string, tag string) (int64, error) {
+
+	// 用etcd进行服务发现, 获得grpc的连接
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	// 创建grpc客户端，调用远程peer的invalidatetag方法
+	grpcClient := pb.NewSpringCacheClient(conn)
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	resp, err := grpcClient.InvalidateTag(ctx, &pb.InvalidateTagRequest{
+		Group: group,
+		Tag:   tag,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("could not invalidate tag %s of %s on peer %s: %w", tag, group, c.Name, err)
+	}
+	return resp.GetRemoved(), nil
+}
+
 // 验证是否实现接口
 var _ PeerGetter = (*Client)(nil)
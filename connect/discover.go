@@ -10,21 +10,26 @@ import (
 )
 
 // DialPeer 传入etcd客户端和节点名，获取与其的grpc连接
-func DialPeer(c *clientv3.Client, service string) (conn *grpc.ClientConn, err error) {
+func DialPeer(c *clientv3.Client, service string, opts ...grpc.DialOption) (conn *grpc.ClientConn, err error) {
+	return DialPeerContext(context.Background(), c, service, opts...)
+}
+
+// DialPeerContext 与 DialPeer 相同，但建立连接的等待时间同时受 ctx 限制，opts 会追加到默认的连接选项之后
+func DialPeerContext(ctx context.Context, c *clientv3.Client, service string, opts ...grpc.DialOption) (conn *grpc.ClientConn, err error) {
 	PeerResolver, err := resolver.NewBuilder(c)
 	if err != nil {
 		return
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
 	defer cancel()
 	//log.Println("In discover.DialPeer, try to get conn, service :", service)
 
-	return grpc.DialContext(ctx,
-		"etcd:///"+service,
+	opts = append([]grpc.DialOption{
 		grpc.WithResolvers(PeerResolver),
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
 		grpc.WithBlock(),
-	)
+	}, opts...)
+	return grpc.DialContext(ctx, "etcd:///"+service, opts...)
 }
 
 // GetAddrByName 是根据服务名在etcd中进行服务发现并返回对应的ip地址
@@ -0,0 +1,92 @@
+package connect
+
+import (
+	"context"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"strconv"
+	"strings"
+)
+
+// Group 的代数保存在 etcd 的 generationPrefix+组名 下，每个节点监听这个前缀，
+// 代数增加后节点上旧的缓存都会失效
+
+const generationPrefix = "springcache/generation/"
+
+// Generation 返回 group 当前的代数，没有设置过时为 0
+func (s *Etcd) Generation(ctx context.Context, group string) (uint64, error) {
+	resp, err := s.EtcdCli.Get(ctx, generationPrefix+group)
+	if err != nil {
+		return 0, err
+	}
+	if len(resp.Kvs) == 0 {
+		return 0, nil
+	}
+	return strconv.ParseUint(string(resp.Kvs[0].Value), 10, 64)
+}
+
+// BumpGeneration 把 group 的代数加一并返回新的代数，并发修改时会重试
+func (s *Etcd) BumpGeneration(ctx context.Context, group string) (uint64, error) {
+	key := generationPrefix + group
+	for {
+		resp, err := s.EtcdCli.Get(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		var gen uint64
+		var rev int64
+		if len(resp.Kvs) > 0 {
+			if gen, err = strconv.ParseUint(string(resp.Kvs[0].Value), 10, 64); err != nil {
+				return 0, err
+			}
+			rev = resp.Kvs[0].ModRevision
+		}
+		gen++
+		// 只有在读取之后没有被其他节点修改过时才写入
+		txn, err := s.EtcdCli.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(key), "=", rev)).
+			Then(clientv3.OpPut(key, strconv.FormatUint(gen, 10))).
+			Commit()
+		if err != nil {
+			return 0, err
+		}
+		if txn.Succeeded {
+			s.log().Info("bump generation", "group", group, "generation", gen)
+			return gen, nil
+		}
+	}
+}
+
+// WatchGenerations 先对所有已经设置的代数调用一次 fn，再在代数变化时调用 fn，
+// 直到 ctx 被取消或者 watch 出错才返回
+func (s *Etcd) WatchGenerations(ctx context.Context, fn func(group string, gen uint64)) error {
+	resp, err := s.EtcdCli.Get(ctx, generationPrefix, clientv3.WithPrefix())
+	if err != nil {
+		return err
+	}
+	for _, kv := range resp.Kvs {
+		s.applyGeneration(kv.Key, kv.Value, fn)
+	}
+	wch := s.EtcdCli.Watch(ctx, generationPrefix, clientv3.WithPrefix(), clientv3.WithRev(resp.Header.Revision+1))
+	for wresp := range wch {
+		if err := wresp.Err(); err != nil {
+			return err
+		}
+		for _, ev := range wresp.Events {
+			if ev.Type == clientv3.EventTypePut {
+				s.applyGeneration(ev.Kv.Key, ev.Kv.Value, fn)
+			}
+		}
+	}
+	return ctx.Err()
+}
+
+// applyGeneration 解析 etcd 中的一个代数并调用 fn
+func (s *Etcd) applyGeneration(key, value []byte, fn func(group string, gen uint64)) {
+	group := strings.TrimPrefix(string(key), generationPrefix)
+	gen, err := strconv.ParseUint(string(value), 10, 64)
+	if err != nil {
+		s.log().Warn("invalid generation", "group", group, "value", string(value), "err", err)
+		return
+	}
+	fn(group, gen)
+}
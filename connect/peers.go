@@ -1,6 +1,10 @@
 package connect
 
-import "time"
+import (
+	pb "SpringCache/springcachepb"
+	"context"
+	"time"
+)
 
 // peers 是用于rpc交流的模块
 
@@ -9,9 +13,22 @@ type PeerPicker interface {
 	PickPeer(key string) (peer PeerGetter, ok bool)
 }
 
+// PeerLister 是 PeerPicker 可以选择实现的接口，返回除自己以外的所有节点，
+// 用于需要通知所有节点的操作，例如按标签失效
+type PeerLister interface {
+	Peers() []PeerGetter
+}
+
 // PeerGetter 定义了从远端获取缓存的能力,Client
 // 在connect.client 包中， 定义了结构体Client, 它有下面的Get方法和Set方法，满足了下面的接口，所以可以作为PeerGetter被使用
 type PeerGetter interface {
-	Get(group string, key string) ([]byte, error)
-	Set(group string, key string, value []byte, expire time.Time, ishot bool) error
+	Get(ctx context.Context, group string, key string) (*pb.GetResponse, error)
+	// compressed 表示 value 是压缩后的字节，由接收方按自己的压缩算法解压
+	Set(ctx context.Context, group string, key string, value []byte, compressed bool, expire time.Time, ishot bool, tags []string) error
+	Delete(ctx context.Context, group string, key string) error
+	// GetMulti 和 SetMulti 在一次请求中处理多个键，单个键的错误放在 MultiItem.Error 中
+	GetMulti(ctx context.Context, group string, keys []string) ([]*pb.MultiItem, error)
+	SetMulti(ctx context.Context, group string, items []*pb.MultiItem, ishot bool) ([]*pb.MultiItem, error)
+	// InvalidateTag 让远端节点删除带有 tag 的所有键，返回删除的个数
+	InvalidateTag(ctx context.Context, group string, tag string) (int64, error)
 }
@@ -1,11 +1,10 @@
 package connect
 
 import (
-	"fmt"
+	"SpringCache/logger"
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.etcd.io/etcd/client/v3/naming/endpoints"
 	"golang.org/x/net/context"
-	"log"
 	"time"
 )
 
@@ -17,6 +16,7 @@ var (
 
 type Etcd struct {
 	EtcdCli *clientv3.Client
+	Logger  logger.Logger    // 为空时不输出日志
 	leaseId clientv3.LeaseID // 租约ID
 	ctx     context.Context
 	cancel  context.CancelFunc
@@ -28,7 +28,6 @@ func NewEtcd(endpoints []string) (*Etcd, error) {
 		DialTimeout: defaultTimeout,
 	})
 	if err != nil {
-		log.Println("create etcd register err:", err)
 		return nil, err
 	}
 	// defer client.Close()
@@ -41,6 +40,10 @@ func NewEtcd(endpoints []string) (*Etcd, error) {
 	return svr, nil
 }
 
+func (s *Etcd) log() logger.Logger {
+	return logger.OrDiscard(s.Logger)
+}
+
 // CreateLease 为注册在etcd上的节点创建租约。 由于服务端无法保证自身是一直可用的，可能会宕机，所以与etcd的租约是有时间期限的，租约一旦过期，服务端存储在etcd上的服务地址信息就会消失。
 // 另一方面，如果服务端是正常运行的，etcd中的地址信息又必须存在，因此发送心跳检测，一旦发现etcd上没有自己的服务地址时，请求重新添加（续租）。
 func (s *Etcd) CreateLease(expireTime int) error {
@@ -50,7 +53,7 @@ func (s *Etcd) CreateLease(expireTime int) error {
 		return err
 	}
 	s.leaseId = res.ID
-	log.Println("create lease success:", s.leaseId)
+	s.log().Info("create lease success", "lease", s.leaseId)
 	return nil
 
 }
@@ -66,21 +69,20 @@ func (s *Etcd) BindLease(server string, addr string) error {
 }
 
 func (s *Etcd) KeepAlive() error {
-	log.Println("keep alive start")
-	log.Println("s.leaseId:", s.leaseId)
+	s.log().Debug("keep alive start", "lease", s.leaseId)
 	KeepRespChan, err := s.EtcdCli.KeepAlive(context.Background(), s.leaseId)
 	if err != nil {
-		log.Println("keep alive err:", err)
+		s.log().Error("keep alive failed", "lease", s.leaseId, "err", err)
 		return err
 	}
 	go func() {
 		for {
 			for KeepResp := range KeepRespChan {
 				if KeepResp == nil {
-					fmt.Println("keep alive is stop")
+					s.log().Warn("keep alive is stop", "lease", s.leaseId)
 					return
 				} else {
-					fmt.Println("keep alive is ok")
+					s.log().Debug("keep alive is ok", "lease", s.leaseId)
 				}
 			}
 			time.Sleep(5 * time.Second)
@@ -119,25 +121,25 @@ func (s *Etcd) RegisterServer(serviceName, addr string) error {
 	// 创建租约
 	err := s.CreateLease(defaultLeaseExpTime)
 	if err != nil {
-		log.Println("create etcd register err:", err)
+		s.log().Error("create lease failed", "service", serviceName, "err", err)
 		return err
 	}
 	// 绑定租约
 	err = s.BindLease(serviceName, addr)
 	if err != nil {
-		log.Println("bind etcd register err:", err)
+		s.log().Error("bind lease failed", "service", serviceName, "err", err)
 		return err
 	}
 	// 心跳检测
 	err = s.KeepAlive()
 	if err != nil {
-		log.Println("keep alive register err:", err)
+		s.log().Error("keep alive register failed", "service", serviceName, "err", err)
 		return err
 	}
 	// 注册服务用于服务发现
 	em, err := endpoints.NewManager(s.EtcdCli, serviceName)
 	if err != nil {
-		log.Println("create etcd register err:", err)
+		s.log().Error("create endpoints manager failed", "service", serviceName, "err", err)
 		return err
 	}
 	return em.AddEndpoint(s.ctx, serviceName+"/"+addr, endpoints.Endpoint{Addr: addr}, clientv3.WithLease(s.leaseId))
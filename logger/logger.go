@@ -0,0 +1,64 @@
+// 这里定义 SpringCache 使用的日志接口，默认不输出任何日志，
+// 使用者可以注入自己的实现，或者用 NewSlog 接入标准库的 log/slog
+package logger
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Logger 是带级别的结构化日志接口，keyvals 是交替出现的键和值，例如
+// l.Info("start grpc server", "addr", addr)
+type Logger interface {
+	Debug(msg string, keyvals ...interface{})
+	Info(msg string, keyvals ...interface{})
+	Warn(msg string, keyvals ...interface{})
+	Error(msg string, keyvals ...interface{})
+}
+
+// Discard 丢弃所有日志，是所有组件的默认 Logger
+var Discard Logger = discard{}
+
+type discard struct{}
+
+func (discard) Debug(string, ...interface{}) {}
+func (discard) Info(string, ...interface{})  {}
+func (discard) Warn(string, ...interface{})  {}
+func (discard) Error(string, ...interface{}) {}
+
+// OrDiscard 在 l 为空时返回 Discard
+func OrDiscard(l Logger) Logger {
+	if l == nil {
+		return Discard
+	}
+	return l
+}
+
+// slogLogger 把日志转发给 slog.Logger
+type slogLogger struct {
+	l *slog.Logger
+}
+
+// NewSlog 返回把日志写到 l 的 Logger，l 为空时使用 slog.Default()
+func NewSlog(l *slog.Logger) Logger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return slogLogger{l: l}
+}
+
+func (s slogLogger) Debug(msg string, keyvals ...interface{}) {
+	s.l.Log(context.Background(), slog.LevelDebug, msg, keyvals...)
+}
+
+func (s slogLogger) Info(msg string, keyvals ...interface{}) {
+	s.l.Log(context.Background(), slog.LevelInfo, msg, keyvals...)
+}
+
+func (s slogLogger) Warn(msg string, keyvals ...interface{}) {
+	s.l.Log(context.Background(), slog.LevelWarn, msg, keyvals...)
+}
+
+func (s slogLogger) Error(msg string, keyvals ...interface{}) {
+	s.l.Log(context.Background(), slog.LevelError, msg, keyvals...)
+}
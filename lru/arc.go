@@ -0,0 +1,126 @@
+package lru
+
+import "container/list"
+
+// arcPolicy 实现自适应替换缓存(ARC)。
+// t1 保存只被访问过一次的键，t2 保存被访问过多次的键，
+// b1、b2 是分别从 t1、t2 淘汰出去的"幽灵"键，只记录键不保存值。
+// 当一个幽灵键再次被加入时，会调整 t1 的目标大小 p，
+// 让策略在偏向最近访问和偏向频繁访问之间自适应，避免扫描类访问冲掉工作集。
+// 由于 Cache 按字节而不是按条目数限制容量，这里以当前驻留的键数作为 ARC 中的容量 c。
+type arcPolicy struct {
+	p              int // t1 的目标大小
+	t1, t2, b1, b2 *list.List
+	items          map[string]*arcItem
+}
+
+type arcItem struct {
+	ele *list.Element
+	in  *list.List // 当前所在的链表
+}
+
+// NewARC 创建自适应替换(ARC)淘汰策略
+func NewARC() Policy {
+	return &arcPolicy{
+		t1:    list.New(),
+		t2:    list.New(),
+		b1:    list.New(),
+		b2:    list.New(),
+		items: make(map[string]*arcItem),
+	}
+}
+
+// resident 返回当前驻留在缓存中的键数，即 ARC 中的容量 c
+func (p *arcPolicy) resident() int {
+	return p.t1.Len() + p.t2.Len()
+}
+
+func (p *arcPolicy) Add(key string) {
+	item, ok := p.items[key]
+	if !ok {
+		p.items[key] = &arcItem{ele: p.t1.PushFront(key), in: p.t1}
+		return
+	}
+	switch item.in {
+	case p.t1, p.t2:
+		p.Access(key)
+		return
+	case p.b1:
+		// 命中 b1，说明 t1 太小了
+		delta := 1
+		if p.b1.Len() < p.b2.Len() {
+			delta = p.b2.Len() / p.b1.Len()
+		}
+		p.p += delta
+		if c := p.resident() + 1; p.p > c {
+			p.p = c
+		}
+	case p.b2:
+		// 命中 b2，说明 t2 太小了
+		delta := 1
+		if p.b2.Len() < p.b1.Len() {
+			delta = p.b1.Len() / p.b2.Len()
+		}
+		p.p -= delta
+		if p.p < 0 {
+			p.p = 0
+		}
+	}
+	p.move(item, key, p.t2)
+}
+
+func (p *arcPolicy) Access(key string) {
+	item, ok := p.items[key]
+	if !ok {
+		return
+	}
+	switch item.in {
+	case p.t1:
+		p.move(item, key, p.t2)
+	case p.t2:
+		p.t2.MoveToFront(item.ele)
+	}
+}
+
+func (p *arcPolicy) Remove(key string) {
+	if item, ok := p.items[key]; ok {
+		item.in.Remove(item.ele)
+		delete(p.items, key)
+	}
+}
+
+func (p *arcPolicy) Evict() (string, bool) {
+	var from, ghost *list.List
+	switch {
+	case p.t1.Len() > 0 && (p.t1.Len() > p.p || p.t2.Len() == 0):
+		from, ghost = p.t1, p.b1
+	case p.t2.Len() > 0:
+		from, ghost = p.t2, p.b2
+	default:
+		return "", false
+	}
+	key := from.Back().Value.(string)
+	p.move(p.items[key], key, ghost)
+	p.trimGhosts()
+	return key, true
+}
+
+func (p *arcPolicy) move(item *arcItem, key string, to *list.List) {
+	item.in.Remove(item.ele)
+	item.ele = to.PushFront(key)
+	item.in = to
+}
+
+// trimGhosts 保证每个幽灵链表不超过当前驻留的键数
+func (p *arcPolicy) trimGhosts() {
+	c := p.resident()
+	if c < 1 {
+		c = 1
+	}
+	for _, ghost := range []*list.List{p.b1, p.b2} {
+		for ghost.Len() > c {
+			key := ghost.Remove(ghost.Back()).(string)
+			delete(p.items, key)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package lru
+
+import (
+	"container/heap"
+	"time"
+)
+
+// expiryHeap 是按过期时间排序的最小堆，堆顶是最早过期的节点，
+// 用于在不访问键的情况下主动清理已经过期的节点。
+// 没有过期时间的节点不会进入堆，它们的 index 为 -1。
+type expiryHeap []*entry
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].expire.Before(h[j].expire) }
+
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *expiryHeap) Push(x interface{}) {
+	e := x.(*entry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *expiryHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	e.index = -1
+	*h = old[:n-1]
+	return e
+}
+
+// track 在节点的过期时间变化后更新它在堆中的位置
+func (h *expiryHeap) track(e *entry) {
+	switch {
+	case e.expire.IsZero() && e.index >= 0:
+		heap.Remove(h, e.index)
+	case e.expire.IsZero():
+	case e.index >= 0:
+		heap.Fix(h, e.index)
+	default:
+		heap.Push(h, e)
+	}
+}
+
+// untrack 把节点从堆中移除
+func (h *expiryHeap) untrack(e *entry) {
+	if e.index >= 0 {
+		heap.Remove(h, e.index)
+	}
+}
+
+// RemoveExpired 删除所有已经过期并且超过了 StaleTTL 保留期的节点，返回删除的个数
+func (c *Cache) RemoveExpired() int {
+	now := c.now()
+	n := 0
+	for len(c.expiry) > 0 && c.dead(c.expiry[0], now) {
+		c.removeElement(c.cache[c.expiry[0].key], EvictExpired)
+		n++
+	}
+	return n
+}
+
+// dead 判断节点在 now 时是否已经过期并且超过了保留期，这样的节点不能再被读取
+func (c *Cache) dead(e *entry, now time.Time) bool {
+	if !e.expired(now) {
+		return false
+	}
+	return c.StaleTTL <= 0 || e.expire.Add(c.StaleTTL).Before(now)
+}
+
+// GetStale 返回 key 对应的值，已经过期但还在 StaleTTL 保留期内的值也会被返回，
+// stale 表示返回的值是否已经过期。它不会被当作一次访问
+func (c *Cache) GetStale(key string) (value Value, stale bool, ok bool) {
+	if ele, ok := c.cache[key]; ok {
+		kv := ele.Value.(*entry)
+		now := c.now()
+		if !c.dead(kv, now) {
+			return kv.value, kv.expired(now), true
+		}
+	}
+	return nil, false, false
+}
@@ -0,0 +1,83 @@
+package lru
+
+import (
+	"container/list"
+	"time"
+)
+
+// 这里的方法只读取缓存，不会改变键的访问顺序、淘汰策略的统计和滑动过期时间，
+// 已经过期但还没有被清理的键会被跳过
+
+// Item 是缓存中一个键值对的只读快照
+type Item struct {
+	Key      string
+	Value    Value
+	Expire   time.Time // 过期时间，为零值时表示永不过期
+	Accessed time.Time // 最近一次被加入或命中的时间
+}
+
+func (e *entry) item() Item {
+	return Item{Key: e.key, Value: e.value, Expire: e.expire, Accessed: e.accessed}
+}
+
+// Peek 返回 key 对应的值，但不把它当作一次访问
+func (c *Cache) Peek(key string) (value Value, ok bool) {
+	if ele, ok := c.cache[key]; ok {
+		if kv := ele.Value.(*entry); !kv.expired(c.now()) {
+			return kv.value, true
+		}
+	}
+	return nil, false
+}
+
+// Contains 判断 key 是否在缓存中且没有过期
+func (c *Cache) Contains(key string) bool {
+	_, ok := c.Peek(key)
+	return ok
+}
+
+// Keys 按从最近访问到最久未访问的顺序返回所有的键
+func (c *Cache) Keys() []string {
+	keys := make([]string, 0, c.ll.Len())
+	c.Range(func(item Item) bool {
+		keys = append(keys, item.Key)
+		return true
+	})
+	return keys
+}
+
+// Range 按从最近访问到最久未访问的顺序对每个键值对调用 fn，fn 返回 false 时停止遍历。
+// fn 中不能修改这个缓存
+func (c *Cache) Range(fn func(item Item) bool) {
+	now := c.now()
+	for ele := c.ll.Front(); ele != nil; ele = ele.Next() {
+		kv := ele.Value.(*entry)
+		if kv.expired(now) {
+			continue
+		}
+		if !fn(kv.item()) {
+			return
+		}
+	}
+}
+
+// Oldest 返回最久未被访问的键值对
+func (c *Cache) Oldest() (item Item, ok bool) {
+	return c.first(c.ll.Back(), (*list.Element).Prev)
+}
+
+// Newest 返回最近被访问的键值对
+func (c *Cache) Newest() (item Item, ok bool) {
+	return c.first(c.ll.Front(), (*list.Element).Next)
+}
+
+// first 从 ele 开始沿 next 方向找到第一个没有过期的键值对
+func (c *Cache) first(ele *list.Element, next func(*list.Element) *list.Element) (Item, bool) {
+	now := c.now()
+	for ; ele != nil; ele = next(ele) {
+		if kv := ele.Value.(*entry); !kv.expired(now) {
+			return kv.item(), true
+		}
+	}
+	return Item{}, false
+}
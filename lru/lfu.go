@@ -0,0 +1,86 @@
+package lru
+
+import "container/list"
+
+// lfuPolicy 淘汰访问次数最少的键，访问次数相同时淘汰最久未被访问的键。
+// 使用频率链表实现，Add、Access 和 Evict 都是 O(1) 的。
+type lfuPolicy struct {
+	freqs *list.List // 按访问次数从小到大排列的 *freqNode
+	items map[string]*lfuItem
+}
+
+type freqNode struct {
+	freq  int
+	items *list.List // 同一访问次数下的键，front 为最近访问
+}
+
+type lfuItem struct {
+	ele  *list.Element // 在 freqNode.items 中的位置
+	node *list.Element // 所属的 freqNode
+}
+
+// NewLFU 创建最不经常使用(LFU)淘汰策略
+func NewLFU() Policy {
+	return &lfuPolicy{
+		freqs: list.New(),
+		items: make(map[string]*lfuItem),
+	}
+}
+
+func (p *lfuPolicy) Add(key string) {
+	if _, ok := p.items[key]; ok {
+		p.Access(key)
+		return
+	}
+	node := p.freqs.Front()
+	if node == nil || node.Value.(*freqNode).freq != 1 {
+		node = p.freqs.PushFront(&freqNode{freq: 1, items: list.New()})
+	}
+	p.items[key] = &lfuItem{
+		ele:  node.Value.(*freqNode).items.PushFront(key),
+		node: node,
+	}
+}
+
+func (p *lfuPolicy) Access(key string) {
+	item, ok := p.items[key]
+	if !ok {
+		return
+	}
+	cur := item.node.Value.(*freqNode)
+	next := item.node.Next()
+	if next == nil || next.Value.(*freqNode).freq != cur.freq+1 {
+		next = p.freqs.InsertAfter(&freqNode{freq: cur.freq + 1, items: list.New()}, item.node)
+	}
+	cur.items.Remove(item.ele)
+	if cur.items.Len() == 0 {
+		p.freqs.Remove(item.node)
+	}
+	item.ele = next.Value.(*freqNode).items.PushFront(key)
+	item.node = next
+}
+
+func (p *lfuPolicy) Remove(key string) {
+	if item, ok := p.items[key]; ok {
+		p.unlink(key, item)
+	}
+}
+
+func (p *lfuPolicy) Evict() (string, bool) {
+	node := p.freqs.Front()
+	if node == nil {
+		return "", false
+	}
+	key := node.Value.(*freqNode).items.Back().Value.(string)
+	p.unlink(key, p.items[key])
+	return key, true
+}
+
+func (p *lfuPolicy) unlink(key string, item *lfuItem) {
+	fn := item.node.Value.(*freqNode)
+	fn.items.Remove(item.ele)
+	if fn.items.Len() == 0 {
+		p.freqs.Remove(item.node)
+	}
+	delete(p.items, key)
+}
@@ -14,47 +14,114 @@ type NowFunc func() time.Time
 
 var nowFunc NowFunc = time.Now
 
+// ExpireMode 决定命中缓存时如何更新键值对的过期时间
+type ExpireMode int
+
+const (
+	// ExpireAbsolute 过期时间固定为 Add 时设置的时间，命中不会延长过期时间
+	ExpireAbsolute ExpireMode = iota
+	// ExpireSliding 每次命中都把过期时间顺延一个空闲超时，一直被访问的键不会过期
+	ExpireSliding
+	// ExpireSlidingAbsolute 命中时顺延一个空闲超时，但不会超过 Add 时设置的过期时间，
+	// 即空闲超时受最长存活时间的限制
+	ExpireSlidingAbsolute
+)
+
 type Cache struct {
 	maxBytes  int64                         // 允许使用的最大内存
 	nbytes    int64                         // 当前已经使用的内存
-	ll        *list.List                    // 双向队列,用于lru算法
+	ll        *list.List                    // 双向队列,按最近访问的顺序保存节点
 	cache     map[string]*list.Element      // 实际保存键值的缓存
+	policy    Policy                        // 淘汰策略,决定内存不足时淘汰哪个键
+	expiry    expiryHeap                    // 按过期时间排序的最小堆,用于主动清理过期节点
 	OnEvicted func(key string, value Value) // 当节点被删除时可以选择性调用回调函数
+	// OnEviction 在节点被删除或值被替换时调用，并带上原因。
+	// 与 OnEvicted 不同，它在 Add 替换已有的值时也会被调用
+	OnEviction func(key string, value Value, reason EvictReason)
 
 	// Now is the Now() function the cache will use to determine
 	// the current time which is used to calculate expired values
 	// Defaults to time.Now()
 	Now NowFunc
-	//
+	// ExpireRandom 是添加到过期时间上的随机抖动的最大值，<= 0 表示不加抖动
 	ExpireRandom time.Duration
+	// ExpireMode 决定命中时是否顺延过期时间，默认为 ExpireAbsolute
+	ExpireMode ExpireMode
+	// IdleTimeout 是滑动过期模式下的空闲超时，<= 0 时使用键值对加入时的存活时间
+	IdleTimeout time.Duration
+	// StaleTTL 是键值对过期后继续保留的时间，保留期间 Get 不会命中，但可以用 GetStale 读取旧值。
+	// <= 0 表示过期后立即删除
+	StaleTTL time.Duration
 }
 
 type entry struct {
-	key     string
-	value   Value
-	expire  time.Time // 过期时间
-	addTime time.Time
+	key      string
+	value    Value
+	expire   time.Time // 过期时间，为零值时表示永不过期
+	deadline time.Time // Add 时设置的过期时间，也是滑动过期时的最长存活时间
+	addTime  time.Time
+	accessed time.Time // 最近一次被加入或命中的时间
+	index    int       // 在 expiry 堆中的下标，不在堆中时为 -1
+}
+
+// expired 判断节点在 now 时是否已经过期
+func (e *entry) expired(now time.Time) bool {
+	return !e.expire.IsZero() && e.expire.Before(now)
 }
 
 type Value interface {
 	Len() int
 }
 
+// New 创建一个使用 LRU 淘汰策略的缓存
 func New(maxBytes int64, onEvicted func(string, Value)) *Cache {
+	return NewWithPolicy(maxBytes, NewLRU(), onEvicted)
+}
+
+// NewWithPolicy 创建一个使用指定淘汰策略的缓存，policy 为 nil 时使用 LRU
+func NewWithPolicy(maxBytes int64, policy Policy, onEvicted func(string, Value)) *Cache {
+	if policy == nil {
+		policy = NewLRU()
+	}
 	return &Cache{
 		maxBytes:     maxBytes,
 		ll:           list.New(),
 		cache:        make(map[string]*list.Element),
+		policy:       policy,
 		OnEvicted:    onEvicted,
 		Now:          nowFunc,
 		ExpireRandom: DefaultExpireRandom,
 	}
 }
 
+// getPolicy 返回当前的淘汰策略，直接构造的 Cache 没有设置策略时按 LRU 处理
+func (c *Cache) getPolicy() Policy {
+	if c.policy == nil {
+		c.policy = NewLRU()
+		for ele := c.ll.Back(); ele != nil; ele = ele.Prev() {
+			c.policy.Add(ele.Value.(*entry).key)
+		}
+	}
+	return c.policy
+}
+
+// now 返回缓存使用的当前时间
+func (c *Cache) now() time.Time {
+	if c.Now == nil {
+		return time.Now()
+	}
+	return c.Now()
+}
+
 func (c *Cache) Len() int {
 	return c.ll.Len()
 }
 
+// Bytes 返回缓存当前占用的内存，即所有键和值的长度之和
+func (c *Cache) Bytes() int64 {
+	return c.nbytes
+}
+
 // Get 是用于处理lru逻辑的函数,当请求到某个key就会把他置为队尾
 func (c *Cache) Get(key string) (value Value, ok bool) {
 	// 如果在缓存中找到对应的节点，则把他移动到队尾
@@ -62,69 +129,144 @@ func (c *Cache) Get(key string) (value Value, ok bool) {
 		// ll.Value 是一个interface{}类型，可以存储任何类型的数据
 		// 所以如果之前链表里存储的是*entry类型，这里就可以断言为*entry类型
 		kv := ele.Value.(*entry)
-		// 如果kv过期了，将它们移除缓存
-		if kv.expire.Before(time.Now()) {
-			c.removeElement(ele)
+		now := c.now()
+		// 如果kv过期了，将它们移除缓存，还在保留期内的旧值留给 GetStale
+		if kv.expired(now) {
+			if c.dead(kv, now) {
+				c.removeElement(ele, EvictExpired)
+			}
 			return nil, false
 		}
-		// 如果没有过期，更新键值对的添加实现为现在
-		expireTime := kv.expire.Sub(kv.addTime)
-		kv.expire = time.Now().Add(expireTime)
-		kv.addTime = time.Now()
+		// 如果没有过期，按过期模式更新过期时间
+		kv.accessed = now
+		c.touch(kv, now)
 		// 双向链表作为队列，队首队尾是相对的，在这里约定 front 为队尾
 		c.ll.MoveToFront(ele)
+		c.getPolicy().Access(key)
 		return kv.value, true
 	}
 	return nil, false
 }
 
+// touch 在命中时按过期模式更新节点的过期时间
+func (c *Cache) touch(kv *entry, now time.Time) {
+	if c.ExpireMode == ExpireAbsolute || kv.deadline.IsZero() {
+		return
+	}
+	kv.expire = c.slidingExpire(kv, now)
+	c.expiry.track(kv)
+}
+
+// slidingExpire 计算滑动过期模式下从 now 开始的新过期时间
+func (c *Cache) slidingExpire(kv *entry, now time.Time) time.Time {
+	idle := c.IdleTimeout
+	if idle <= 0 {
+		idle = kv.deadline.Sub(kv.addTime)
+	}
+	expire := now.Add(idle)
+	if c.ExpireMode == ExpireSlidingAbsolute && kv.deadline.Before(expire) {
+		expire = kv.deadline
+	}
+	return expire
+}
+
+// RemoveOldest 按淘汰策略淘汰一个节点，对于 LRU 来说就是最久未被访问的节点
 func (c *Cache) RemoveOldest() {
-	ele := c.ll.Back()
-	if ele != nil {
-		// 在lru队列中删除这个节点
-		c.ll.Remove(ele)
-		kv := ele.Value.(*entry)
-		// 在缓存中删除这个节点
-		delete(c.cache, kv.key)
-		c.nbytes -= int64(len(kv.key)) + int64(kv.value.Len())
-		if c.OnEvicted != nil {
-			c.OnEvicted(kv.key, kv.value)
-		}
+	c.evict()
+}
+
+// evict 淘汰一个由策略选出的节点，没有可淘汰的节点时返回 false
+func (c *Cache) evict() bool {
+	key, ok := c.getPolicy().Evict()
+	if !ok {
+		return false
 	}
+	if ele, ok := c.cache[key]; ok {
+		c.deleteElement(ele, EvictCapacity)
+	}
+	return true
 }
 
 func (c *Cache) Remove(key string) {
 	if ele, ok := c.cache[key]; ok {
-		c.removeElement(ele)
+		c.removeElement(ele, EvictRemoved)
+	}
+}
+
+// Clear 删除所有节点，每个节点都会以 EvictRemoved 为原因触发回调
+func (c *Cache) Clear() {
+	for ele := c.ll.Front(); ele != nil; ele = c.ll.Front() {
+		c.removeElement(ele, EvictRemoved)
 	}
 }
 
-func (c *Cache) removeElement(ele *list.Element) {
+// removeElement 删除节点并通知淘汰策略
+func (c *Cache) removeElement(ele *list.Element, reason EvictReason) {
+	c.getPolicy().Remove(ele.Value.(*entry).key)
+	c.deleteElement(ele, reason)
+}
+
+// deleteElement 在lru队列和缓存中删除这个节点
+func (c *Cache) deleteElement(ele *list.Element, reason EvictReason) {
 	kv := ele.Value.(*entry)
+	c.ll.Remove(ele)
+	c.expiry.untrack(kv)
 	delete(c.cache, kv.key)
 	c.nbytes -= int64(len(kv.key)) + int64(kv.value.Len())
 	if c.OnEvicted != nil {
 		c.OnEvicted(kv.key, kv.value)
 	}
+	if c.OnEviction != nil {
+		c.OnEviction(kv.key, kv.value, reason)
+	}
 }
 
 func (c *Cache) Add(key string, value Value, expire time.Time) {
-	// randDuration 是用户添加的过期时间进行一定范围的随机，用于防止大量缓存同一时间过期而发生缓存雪崩
-	randDuration := time.Duration(rand.Int63n(int64(c.ExpireRandom)))
+	// 给过期时间加上一定范围的随机，用于防止大量缓存同一时间过期而发生缓存雪崩
+	if !expire.IsZero() && c.ExpireRandom > 0 {
+		expire = expire.Add(time.Duration(rand.Int63n(int64(c.ExpireRandom))))
+	}
+	c.AddExact(key, value, expire)
+}
 
+// AddExact 与 Add 相同，但不给过期时间加随机抖动，
+// 用于恢复快照等需要保留原有过期时间的场景
+func (c *Cache) AddExact(key string, value Value, expire time.Time) {
+	now := c.now()
 	if ele, ok := c.cache[key]; ok {
 		// 如果key已经存在则将value替换
 		c.ll.MoveToFront(ele)
 		kv := ele.Value.(*entry)
 		c.nbytes += int64(value.Len()) - int64(kv.value.Len())
+		old := kv.value
 		kv.value = value
-		kv.expire = expire.Add(randDuration)
+		c.setExpire(kv, expire, now)
+		c.getPolicy().Access(key)
+		if c.OnEviction != nil {
+			c.OnEviction(key, old, EvictReplaced)
+		}
 	} else {
-		newEle := c.ll.PushFront(&entry{key, value, expire.Add(randDuration), time.Now()})
-		c.cache[key] = newEle
+		kv := &entry{key: key, value: value, index: -1}
+		c.cache[key] = c.ll.PushFront(kv)
+		c.setExpire(kv, expire, now)
 		c.nbytes += int64(len(key)) + int64(value.Len())
+		c.getPolicy().Add(key)
 	}
 	for c.maxBytes != 0 && c.maxBytes < c.nbytes {
-		c.RemoveOldest()
+		if !c.evict() {
+			break
+		}
+	}
+}
+
+// setExpire 为新加入或被替换的节点设置过期时间
+func (c *Cache) setExpire(kv *entry, deadline time.Time, now time.Time) {
+	kv.deadline = deadline
+	kv.addTime = now
+	kv.accessed = now
+	kv.expire = deadline
+	if c.ExpireMode != ExpireAbsolute && !deadline.IsZero() && c.IdleTimeout > 0 {
+		kv.expire = c.slidingExpire(kv, now)
 	}
+	c.expiry.track(kv)
 }
@@ -2,7 +2,10 @@ package lru
 
 import (
 	"container/list"
+	"fmt"
+	"reflect"
 	"testing"
+	"time"
 )
 
 type String string
@@ -13,7 +16,7 @@ func (d String) Len() int {
 func TestGet(t *testing.T) {
 	lru := New(int64(0), nil)
 	lru.Add(
-		"key1", String("1234"),
+		"key1", String("1234"), time.Now().Add(time.Minute),
 	)
 	if v, ok := lru.Get("key1"); !ok || string(v.(String)) != "1234" {
 		t.Fatalf("cache hit key1=1234 failed")
@@ -59,3 +62,285 @@ func TestCache_RemoveOldest(t *testing.T) {
 		})
 	}
 }
+
+func TestPolicies(t *testing.T) {
+	policies := map[string]NewPolicyFunc{
+		"lru":     NewLRU,
+		"lfu":     NewLFU,
+		"arc":     NewARC,
+		"tinylfu": NewTinyLFU,
+	}
+	for name, newPolicy := range policies {
+		t.Run(name, func(t *testing.T) {
+			// 每个键值对占用 3 字节，最多容纳 4 个
+			c := NewWithPolicy(12, newPolicy(), nil)
+			expire := time.Now().Add(time.Hour)
+			for i := 0; i < 10; i++ {
+				c.Add(fmt.Sprintf("k%d", i), String("v"), expire)
+				if c.nbytes > c.maxBytes {
+					t.Fatalf("nbytes %d exceeds maxBytes %d", c.nbytes, c.maxBytes)
+				}
+			}
+			if c.Len() != 4 || len(c.cache) != 4 {
+				t.Fatalf("expected 4 entries, got %d", c.Len())
+			}
+			c.Remove("k9")
+			c.Remove("k8")
+			for c.Len() > 0 {
+				c.RemoveOldest()
+			}
+			if c.nbytes != 0 {
+				t.Fatalf("expected empty cache, nbytes=%d", c.nbytes)
+			}
+		})
+	}
+}
+
+func TestLFUEvictsLeastFrequent(t *testing.T) {
+	c := NewWithPolicy(6, NewLFU(), nil)
+	expire := time.Now().Add(time.Hour)
+	c.Add("a", String("1"), expire)
+	c.Add("b", String("1"), expire)
+	c.Add("c", String("1"), expire)
+	c.Get("a")
+	c.Get("c")
+	c.Add("d", String("1"), expire)
+	if _, ok := c.Get("b"); ok {
+		t.Fatalf("expected b to be evicted")
+	}
+	for _, k := range []string{"a", "c", "d"} {
+		if _, ok := c.Get(k); !ok {
+			t.Fatalf("expected %s to stay in cache", k)
+		}
+	}
+}
+
+// 一次性的扫描不应当把反复访问的工作集挤出缓存
+func TestScanResistance(t *testing.T) {
+	for name, newPolicy := range map[string]NewPolicyFunc{"arc": NewARC, "tinylfu": NewTinyLFU} {
+		t.Run(name, func(t *testing.T) {
+			c := NewWithPolicy(40, newPolicy(), nil)
+			expire := time.Now().Add(time.Hour)
+			hot := []string{"h0", "h1", "h2", "h3", "h4"}
+			for round := 0; round < 5; round++ {
+				for _, k := range hot {
+					if _, ok := c.Get(k); !ok {
+						c.Add(k, String("vv"), expire)
+					}
+				}
+			}
+			for i := 0; i < 100; i++ {
+				c.Add(fmt.Sprintf("s%02d", i), String("v"), expire)
+			}
+			kept := 0
+			for _, k := range hot {
+				if _, ok := c.Get(k); ok {
+					kept++
+				}
+			}
+			if kept < len(hot)/2 {
+				t.Fatalf("scan evicted the working set, only %d/%d hot keys kept", kept, len(hot))
+			}
+		})
+	}
+}
+
+// 候选者进入试用区之后，试用区的命中会把保护区溢出的键降级到试用区前端，
+// 淘汰时仍然要拿新的候选者而不是降级的键去做频率比较
+func TestTinyLFUAdmission(t *testing.T) {
+	p := NewTinyLFU().(*tinyLFUPolicy)
+	p.Add("old")
+	var hot []string
+	for i := 0; i < 16; i++ {
+		hot = append(hot, fmt.Sprintf("h%02d", i))
+		p.Add(hot[i])
+	}
+	p.Add("w")
+	for round := 0; round < 3; round++ {
+		for _, k := range hot {
+			p.Access(k)
+		}
+	}
+	p.Add("new")
+	p.Add("x")
+	// h00~h02 此时在试用区，再次命中后晋升，保护区溢出的键降级到试用区前端
+	for _, k := range []string{"h02", "h01", "h00"} {
+		p.Access(k)
+	}
+	if front := p.probation.Front().Value.(string); front[0] != 'h' {
+		t.Fatalf("expected a demoted hot key at the front of probation, got %s", front)
+	}
+	if key, _ := p.Evict(); key != "new" {
+		t.Fatalf("expected the new candidate to lose the admission check, evicted %s", key)
+	}
+	for _, k := range hot {
+		if _, ok := p.items[k]; !ok {
+			t.Fatalf("expected %s to stay in cache", k)
+		}
+	}
+}
+
+func TestRemoveExpired(t *testing.T) {
+	now := time.Now()
+	c := New(0, nil)
+	c.Now = func() time.Time { return now }
+	c.ExpireRandom = 1
+	c.Add("a", String("1"), now.Add(time.Second))
+	c.Add("b", String("2"), now.Add(time.Hour))
+	c.Add("c", String("3"), now.Add(2*time.Second))
+	if n := c.RemoveExpired(); n != 0 {
+		t.Fatalf("expected nothing to expire, removed %d", n)
+	}
+	now = now.Add(3 * time.Second)
+	if n := c.RemoveExpired(); n != 2 {
+		t.Fatalf("expected 2 expired entries, removed %d", n)
+	}
+	if c.Len() != 1 || len(c.expiry) != 1 || c.nbytes != 2 {
+		t.Fatalf("unexpected state after sweep: len=%d heap=%d nbytes=%d", c.Len(), len(c.expiry), c.nbytes)
+	}
+	if _, ok := c.Get("b"); !ok {
+		t.Fatalf("expected b to survive the sweep")
+	}
+}
+
+func TestAddExact(t *testing.T) {
+	now := time.Now()
+	c := New(0, nil)
+	c.Now = func() time.Time { return now }
+	c.ExpireRandom = time.Hour
+	c.AddExact("a", String("1"), now.Add(time.Second))
+	now = now.Add(2 * time.Second)
+	if _, ok := c.Get("a"); ok {
+		t.Fatalf("expected AddExact to keep the expire time without jitter")
+	}
+}
+
+func TestStaleTTL(t *testing.T) {
+	now := time.Now()
+	c := New(0, nil)
+	c.Now = func() time.Time { return now }
+	c.ExpireRandom = 0
+	c.StaleTTL = time.Minute
+	c.Add("a", String("1"), now.Add(time.Second))
+
+	now = now.Add(30 * time.Second)
+	if _, ok := c.Get("a"); ok {
+		t.Fatalf("expected a to be expired")
+	}
+	if n := c.RemoveExpired(); n != 0 {
+		t.Fatalf("expected stale entry to be kept, removed %d", n)
+	}
+	if v, stale, ok := c.GetStale("a"); !ok || !stale || v.(String) != "1" {
+		t.Fatalf("expected stale value of a, got %v stale=%v ok=%v", v, stale, ok)
+	}
+	now = now.Add(time.Minute)
+	if _, _, ok := c.GetStale("a"); ok {
+		t.Fatalf("expected a to be dropped after the stale window")
+	}
+	if n := c.RemoveExpired(); n != 1 || c.Len() != 0 {
+		t.Fatalf("expected a to be removed, removed %d", n)
+	}
+}
+
+func TestExpireMode(t *testing.T) {
+	start := time.Now()
+	tests := []struct {
+		name    string
+		mode    ExpireMode
+		idle    time.Duration
+		alive   []time.Duration // 这些时刻访问时应当命中
+		expired time.Duration   // 这个时刻访问时应当已经过期
+	}{
+		{"absolute", ExpireAbsolute, 0, []time.Duration{4 * time.Second, 8 * time.Second}, 11 * time.Second},
+		{"sliding", ExpireSliding, 5 * time.Second, []time.Duration{4 * time.Second, 8 * time.Second, 12 * time.Second, 16 * time.Second}, 22 * time.Second},
+		{"sliding with max lifetime", ExpireSlidingAbsolute, 5 * time.Second, []time.Duration{4 * time.Second, 8 * time.Second}, 11 * time.Second},
+		{"sliding idle timeout", ExpireSlidingAbsolute, 5 * time.Second, []time.Duration{4 * time.Second}, 10*time.Second - time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := start
+			c := New(0, nil)
+			c.Now = func() time.Time { return now }
+			c.ExpireRandom = 0
+			c.ExpireMode = tt.mode
+			c.IdleTimeout = tt.idle
+			// 最长存活 10 秒
+			c.Add("key", String("v"), start.Add(10*time.Second))
+			for _, d := range tt.alive {
+				now = start.Add(d)
+				if _, ok := c.Get("key"); !ok {
+					t.Fatalf("expected key to be alive at %v", d)
+				}
+			}
+			now = start.Add(tt.expired)
+			if _, ok := c.Get("key"); ok {
+				t.Fatalf("expected key to be expired at %v", tt.expired)
+			}
+		})
+	}
+}
+
+func TestInspect(t *testing.T) {
+	now := time.Now()
+	c := New(6, nil)
+	c.Now = func() time.Time { return now }
+	c.ExpireRandom = 0
+	c.ExpireMode = ExpireSliding
+	c.Add("a", String("1"), now.Add(time.Minute))
+	c.Add("b", String("2"), now.Add(time.Minute))
+	c.Add("c", String("3"), now.Add(time.Minute))
+
+	if v, ok := c.Peek("a"); !ok || string(v.(String)) != "1" {
+		t.Fatalf("peek a failed")
+	}
+	if item, ok := c.Oldest(); !ok || item.Key != "a" {
+		t.Fatalf("peek should not promote a, oldest=%v", item.Key)
+	}
+	if item, ok := c.Newest(); !ok || item.Key != "c" {
+		t.Fatalf("expected newest to be c, got %v", item.Key)
+	}
+	if keys := c.Keys(); !reflect.DeepEqual(keys, []string{"c", "b", "a"}) {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+	// 淘汰时 a 仍然是最久未访问的键
+	c.Add("d", String("4"), now.Add(time.Minute))
+	if _, ok := c.cache["a"]; ok {
+		t.Fatalf("expected a to be evicted")
+	}
+	// Peek 不顺延滑动过期时间
+	now = now.Add(50 * time.Second)
+	c.Peek("b")
+	now = now.Add(20 * time.Second)
+	if c.Contains("b") {
+		t.Fatalf("peek should not extend the sliding expiry")
+	}
+}
+
+func TestEvictReason(t *testing.T) {
+	now := time.Now()
+	got := make(map[string]EvictReason)
+	c := New(6, nil)
+	c.Now = func() time.Time { return now }
+	c.ExpireRandom = 0
+	c.OnEviction = func(key string, value Value, reason EvictReason) {
+		got[key+"="+string(value.(String))] = reason
+	}
+	c.Add("a", String("1"), now.Add(time.Minute))
+	c.Add("a", String("2"), now.Add(time.Minute))
+	c.Add("b", String("1"), now.Add(time.Second))
+	c.Add("c", String("1"), now.Add(time.Minute))
+	c.Add("d", String("1"), now.Add(time.Minute))
+	c.Remove("c")
+	now = now.Add(2 * time.Second)
+	c.RemoveExpired()
+
+	expect := map[string]EvictReason{
+		"a=1": EvictReplaced,
+		"a=2": EvictCapacity,
+		"c=1": EvictRemoved,
+		"b=1": EvictExpired,
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Fatalf("unexpected eviction events %v", got)
+	}
+}
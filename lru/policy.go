@@ -0,0 +1,65 @@
+package lru
+
+import "container/list"
+
+// Policy 是淘汰策略的抽象，Cache 只负责存储键值和统计内存，
+// 当内存超出限制时由 Policy 决定淘汰哪一个键。
+// Policy 的方法都在 Cache 的调用方持有锁的情况下被调用，实现无需自己加锁。
+type Policy interface {
+	// Add 记录一个新加入缓存的键
+	Add(key string)
+	// Access 记录一次对已有键的访问(命中或替换)
+	Access(key string)
+	// Remove 在键被显式删除或过期时调用，不应当被视为一次淘汰
+	Remove(key string)
+	// Evict 选出一个要淘汰的键并把它从策略中移除，没有可淘汰的键时返回 false
+	Evict() (key string, ok bool)
+}
+
+// NewPolicyFunc 用于创建淘汰策略，每个 Cache 都需要自己独立的 Policy 实例
+type NewPolicyFunc func() Policy
+
+// lruPolicy 淘汰最近最少使用的键
+type lruPolicy struct {
+	ll    *list.List // 约定 front 为队尾(最近使用)
+	items map[string]*list.Element
+}
+
+// NewLRU 创建最近最少使用(LRU)淘汰策略
+func NewLRU() Policy {
+	return &lruPolicy{
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func (p *lruPolicy) Add(key string) {
+	if ele, ok := p.items[key]; ok {
+		p.ll.MoveToFront(ele)
+		return
+	}
+	p.items[key] = p.ll.PushFront(key)
+}
+
+func (p *lruPolicy) Access(key string) {
+	if ele, ok := p.items[key]; ok {
+		p.ll.MoveToFront(ele)
+	}
+}
+
+func (p *lruPolicy) Remove(key string) {
+	if ele, ok := p.items[key]; ok {
+		p.ll.Remove(ele)
+		delete(p.items, key)
+	}
+}
+
+func (p *lruPolicy) Evict() (string, bool) {
+	ele := p.ll.Back()
+	if ele == nil {
+		return "", false
+	}
+	key := p.ll.Remove(ele).(string)
+	delete(p.items, key)
+	return key, true
+}
@@ -0,0 +1,29 @@
+package lru
+
+// EvictReason 表示一个键值对离开缓存的原因
+type EvictReason int
+
+const (
+	// EvictCapacity 表示内存不足时被淘汰策略淘汰
+	EvictCapacity EvictReason = iota
+	// EvictExpired 表示已经过期，在访问或主动清理时被删除
+	EvictExpired
+	// EvictRemoved 表示被 Remove 显式删除
+	EvictRemoved
+	// EvictReplaced 表示 Add 同一个键时旧的值被替换
+	EvictReplaced
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictCapacity:
+		return "capacity"
+	case EvictExpired:
+		return "expired"
+	case EvictRemoved:
+		return "removed"
+	case EvictReplaced:
+		return "replaced"
+	}
+	return "unknown"
+}
@@ -0,0 +1,154 @@
+package lru
+
+import (
+	"SpringCache/sketch"
+	"container/list"
+)
+
+const (
+	tinyLFUSketchWidth  = 1 << 12
+	tinyLFUWindowRatio  = 100 // 窗口区占全部键数的 1%
+	tinyLFUProtectRatio = 0.8 // 保护区占主区键数的 80%
+)
+
+// tinyLFUPolicy 实现 W-TinyLFU。
+// 新键先进入一个很小的 LRU 窗口区，窗口区溢出时，窗口中最旧的键进入主区的试用区，成为候选者。
+// 需要淘汰时，候选者要和试用区中最旧的键比较 Count-Min Sketch 估计出的访问频率，
+// 频率更高的一方才能留在缓存中。
+// 主区是分段 LRU：试用区(probation)中的键被再次访问后晋升到保护区(protected)。
+// 这样一次性的扫描访问只能停留在窗口区，无法把主区中的工作集挤出去。
+type tinyLFUPolicy struct {
+	sketch    *sketch.CountMin
+	window    *list.List
+	probation *list.List
+	protected *list.List
+	items     map[string]*tinyLFUItem
+	// candidates 按进入试用区的顺序保存还没有经过频率比较的候选者，最新的在前端
+	candidates *list.List
+}
+
+type tinyLFUItem struct {
+	ele  *list.Element
+	in   *list.List
+	cand *list.Element // 在 candidates 中的位置，不是候选者时为 nil
+}
+
+// NewTinyLFU 创建 W-TinyLFU 淘汰策略
+func NewTinyLFU() Policy {
+	return &tinyLFUPolicy{
+		sketch:     sketch.New(tinyLFUSketchWidth),
+		window:     list.New(),
+		probation:  list.New(),
+		protected:  list.New(),
+		items:      make(map[string]*tinyLFUItem),
+		candidates: list.New(),
+	}
+}
+
+func (p *tinyLFUPolicy) windowCap() int {
+	c := len(p.items) / tinyLFUWindowRatio
+	if c < 1 {
+		c = 1
+	}
+	return c
+}
+
+func (p *tinyLFUPolicy) protectedCap() int {
+	c := int(float64(p.probation.Len()+p.protected.Len()) * tinyLFUProtectRatio)
+	if c < 1 {
+		c = 1
+	}
+	return c
+}
+
+func (p *tinyLFUPolicy) Add(key string) {
+	if _, ok := p.items[key]; ok {
+		p.Access(key)
+		return
+	}
+	p.sketch.Increment(key)
+	p.items[key] = &tinyLFUItem{ele: p.window.PushFront(key), in: p.window}
+	for p.window.Len() > p.windowCap() {
+		oldest := p.window.Back().Value.(string)
+		item := p.items[oldest]
+		p.move(item, oldest, p.probation)
+		item.cand = p.candidates.PushFront(oldest)
+	}
+}
+
+func (p *tinyLFUPolicy) Access(key string) {
+	item, ok := p.items[key]
+	if !ok {
+		return
+	}
+	p.sketch.Increment(key)
+	switch item.in {
+	case p.window, p.protected:
+		item.in.MoveToFront(item.ele)
+	case p.probation:
+		// 候选者被再次访问，说明它不是一次性的访问，不再需要经过频率比较
+		p.unmark(item)
+		p.move(item, key, p.protected)
+		for p.protected.Len() > p.protectedCap() {
+			demoted := p.protected.Back().Value.(string)
+			p.move(p.items[demoted], demoted, p.probation)
+		}
+	}
+}
+
+func (p *tinyLFUPolicy) Remove(key string) {
+	if item, ok := p.items[key]; ok {
+		p.unmark(item)
+		item.in.Remove(item.ele)
+		delete(p.items, key)
+	}
+}
+
+// unmark 让 item 不再是候选者
+func (p *tinyLFUPolicy) unmark(item *tinyLFUItem) {
+	if item.cand != nil {
+		p.candidates.Remove(item.cand)
+		item.cand = nil
+	}
+}
+
+func (p *tinyLFUPolicy) Evict() (string, bool) {
+	if ele := p.candidates.Front(); ele != nil {
+		candidate := ele.Value.(string)
+		p.unmark(p.items[candidate])
+		victim, _ := p.mainVictim()
+		if candidate != victim && p.sketch.Estimate(candidate) > p.sketch.Estimate(victim) {
+			p.Remove(victim)
+			return victim, true
+		}
+		p.Remove(candidate)
+		return candidate, true
+	}
+	if victim, ok := p.mainVictim(); ok {
+		p.Remove(victim)
+		return victim, true
+	}
+	if ele := p.window.Back(); ele != nil {
+		key := ele.Value.(string)
+		p.Remove(key)
+		return key, true
+	}
+	return "", false
+}
+
+// mainVictim 返回主区中下一个应该被淘汰的键，优先选择试用区
+func (p *tinyLFUPolicy) mainVictim() (string, bool) {
+	if ele := p.probation.Back(); ele != nil {
+		return ele.Value.(string), true
+	}
+	if ele := p.protected.Back(); ele != nil {
+		return ele.Value.(string), true
+	}
+	return "", false
+}
+
+func (p *tinyLFUPolicy) move(item *tinyLFUItem, key string, to *list.List) {
+	item.in.Remove(item.ele)
+	item.ele = to.PushFront(key)
+	item.in = to
+}
@@ -1,11 +1,16 @@
 package singleflight
 
-import "sync"
+import (
+	"context"
+	"sync"
+)
 
 type call struct {
-	wg  sync.WaitGroup
-	val interface{}
-	err error
+	done    chan struct{} // 请求结束后关闭
+	val     interface{}
+	err     error
+	waiters int                // 还在等待结果的调用者个数
+	cancel  context.CancelFunc // 所有调用者都放弃等待时取消请求
 }
 
 type Group struct {
@@ -14,27 +19,65 @@ type Group struct {
 }
 
 func (g *Group) DoOnce(key string, fn func() (interface{}, error)) (interface{}, error) {
+	return g.DoContext(context.Background(), key, func(context.Context) (interface{}, error) {
+		return fn()
+	})
+}
+
+// DoContext 与 DoOnce 一样保证同一时间对同一个 key 只执行一次 fn，并且会感知调用者的 ctx：
+// 某个调用者的 ctx 被取消或超时后，它会立即返回 ctx.Err()，而不影响其他仍在等待的调用者；
+// 只有所有调用者都放弃等待时，传给 fn 的 ctx 才会被取消。
+// 传给 fn 的 ctx 保留第一个调用者 ctx 中的值，但不继承它的截止时间，
+// 否则一个很快超时的调用者会让所有共享这次请求的调用者一起失败。
+func (g *Group) DoContext(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	v, err, _ := g.DoContextShared(ctx, key, fn)
+	return v, err
+}
+
+// DoContextShared 与 DoContext 相同，shared 表示这次调用是否复用了其他调用者正在进行的请求
+func (g *Group) DoContextShared(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (v interface{}, err error, shared bool) {
 	g.mu.Lock()
 	if g.m == nil {
 		g.m = make(map[string]*call)
 	}
-	if c, ok := g.m[key]; ok {
-		// 如果g.m[key]不为空，则说明已经有其他线程在请求该key，则等待其他请求结束后一起返回
-		g.mu.Unlock()
-		c.wg.Wait()
-		return c.val, c.err
+	c, ok := g.m[key]
+	if !ok {
+		// 如果g.m[key]为空，则发起请求并加到map中，表示该key已经在请求
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		c = &call{done: make(chan struct{}), cancel: cancel}
+		g.m[key] = c
+		go g.run(callCtx, key, c, fn)
 	}
-	c := new(call)
-	c.wg.Add(1)  // 发起请求前加锁
-	g.m[key] = c // 加到map中，表示该key已经在请求
+	// 如果g.m[key]不为空，则说明已经有其他线程在请求该key，则等待其他请求结束后一起返回
+	c.waiters++
 	g.mu.Unlock()
 
-	c.val, c.err = fn() // 执行请求
-	c.wg.Done()         // 请求结束
+	select {
+	case <-c.done:
+		return c.val, c.err, ok
+	case <-ctx.Done():
+		g.mu.Lock()
+		c.waiters--
+		if c.waiters == 0 {
+			// 没有人再等待这个结果了，取消请求，之后的调用者会重新发起请求
+			c.cancel()
+			if g.m[key] == c {
+				delete(g.m, key)
+			}
+		}
+		g.mu.Unlock()
+		return nil, ctx.Err(), ok
+	}
+}
+
+func (g *Group) run(ctx context.Context, key string, c *call, fn func(ctx context.Context) (interface{}, error)) {
+	c.val, c.err = fn(ctx) // 执行请求
+	c.cancel()
 
 	g.mu.Lock()
-	delete(g.m, key) // 删除该key，已经执行完毕
+	if g.m[key] == c {
+		delete(g.m, key) // 删除该key，已经执行完毕
+	}
 	g.mu.Unlock()
-
-	return c.val, c.err
+	close(c.done) // 请求结束
 }
@@ -0,0 +1,88 @@
+// 这里实现带衰减的 Count-Min Sketch，用于近似统计键的访问频率
+package sketch
+
+import "github.com/segmentio/fasthash/fnv1"
+
+const (
+	depth      = 4   // 哈希函数(行)的个数
+	maxCounter = 255 // 计数器上限
+	resetRatio = 10  // 累计增加 width*resetRatio 次后，所有计数减半
+)
+
+// CountMin 用 depth 行计数器近似记录每个键出现的次数，
+// 估计值取各行中的最小值。计数会周期性减半，使统计结果偏向近期的访问。
+type CountMin struct {
+	rows  [depth][]uint8
+	mask  uint64
+	adds  int
+	limit int
+}
+
+// New 创建一个每行至少 width 个计数器的 sketch，width 会向上取整到 2 的幂
+func New(width int) *CountMin {
+	w := 16
+	for w < width {
+		w <<= 1
+	}
+	s := &CountMin{
+		mask:  uint64(w - 1),
+		limit: w * resetRatio,
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, w)
+	}
+	return s
+}
+
+// index 使用双重哈希 h1 + i*h2 为第 i 行计算下标
+func (s *CountMin) index(h uint64, i int) uint64 {
+	h1, h2 := h&0xffffffff, h>>32
+	return (h1 + uint64(i)*h2) & s.mask
+}
+
+// Increment 把 key 的计数加一
+func (s *CountMin) Increment(key string) {
+	h := fnv1.HashString64(key)
+	for i := range s.rows {
+		idx := s.index(h, i)
+		if s.rows[i][idx] < maxCounter {
+			s.rows[i][idx]++
+		}
+	}
+	s.adds++
+	if s.adds >= s.limit {
+		s.halve()
+	}
+}
+
+// Estimate 返回 key 计数的估计值
+func (s *CountMin) Estimate(key string) int {
+	h := fnv1.HashString64(key)
+	min := maxCounter
+	for i := range s.rows {
+		if c := int(s.rows[i][s.index(h, i)]); c < min {
+			min = c
+		}
+	}
+	return min
+}
+
+// Reset 清空所有计数
+func (s *CountMin) Reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] = 0
+		}
+	}
+	s.adds = 0
+}
+
+// halve 把所有计数减半，让很久以前的访问逐渐失去影响
+func (s *CountMin) halve() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.adds /= 2
+}
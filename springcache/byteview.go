@@ -5,10 +5,15 @@ import "time"
 // A ByteView holds an immutable view of bytes.
 // ByteView 用来表示缓存值，是SpringCache的存储单元，它实现了lru的Value接口，所以可以直接在lru里面进行存储
 type ByteView struct {
-	b []byte
-	e time.Time
+	b     []byte
+	e     time.Time
+	stale bool       // 是否是加载失败时返回的过期旧值
+	zip   Compressor // 不为空时 b 是用它压缩后的字节
+	gen   uint64     // 写入缓存时 Group 的代数
+	tags  []string   // 值的标签，用于按标签失效
 }
 
+// Len 返回值占用的内存，压缩过的值返回压缩后的大小
 func (v *ByteView) Len() int {
 	return len(v.b)
 }
@@ -18,12 +23,48 @@ func (v ByteView) Expire() time.Time {
 	return v.e
 }
 
+// Stale 表示这个值已经过期，是在数据源或远端节点出错时作为兜底返回的旧值
+func (v *ByteView) Stale() bool {
+	return v.stale
+}
+
+// Tags 返回值的标签
+func (v *ByteView) Tags() []string {
+	return append([]string(nil), v.tags...)
+}
+
+// Compressed 表示值在缓存中是否以压缩的形式保存
+func (v *ByteView) Compressed() bool {
+	return v.zip != nil
+}
+
 func (v *ByteView) ByteSlice() []byte {
+	if v.zip != nil {
+		return v.data()
+	}
 	return cloneBytes(v.b)
 }
 
 func (v *ByteView) String() string {
-	return string(v.b)
+	return string(v.data())
+}
+
+// data 返回解压后的字节，没有压缩时直接返回底层的切片，调用方不能修改它。
+// 解压失败时返回 nil，进入缓存的值都已经检查过可以解压
+func (v *ByteView) data() []byte {
+	b, err := v.decompress()
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// decompress 与 data 相同，但返回解压失败的错误
+func (v *ByteView) decompress() ([]byte, error) {
+	if v.zip == nil {
+		return v.b, nil
+	}
+	return v.zip.Decompress(v.b)
 }
 
 func cloneBytes(b []byte) []byte {
@@ -1,40 +1,380 @@
 package springcache
 
 import (
+	"SpringCache/consistenthash"
 	"SpringCache/lru"
+	"github.com/segmentio/fasthash/fnv1"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// DefaultCacheShards 是每个缓存默认的分片数
+var DefaultCacheShards = 16
+
+// minShardBytes 是每个分片至少分到的内存，缓存太小时会相应减少分片数，
+// 避免每个分片的容量过小而让淘汰变得不准确
+const minShardBytes = 64 << 10
+
 // 设计一个并发缓存
+// 缓存被分成多个分片，每个分片有自己的锁和内存限制，不同分片上的键可以被并发访问，
+// 避免所有请求争抢同一把锁
 type cache struct {
-	mu         sync.Mutex
-	lru        *lru.Cache
 	cacheBytes int64
+	minBytes   int64               // 每个分片的最小内存，<= 0 时使用 lru.DefaultMaxBytes
+	jitter     time.Duration       // 过期时间的随机抖动范围，0 表示不加抖动
+	hash       consistenthash.Hash // 选择分片的哈希函数，为空时使用 fnv1
+	shardCount int                 // 分片数，<= 0 时使用 DefaultCacheShards
+	newPolicy  lru.NewPolicyFunc   // 淘汰策略，为空时使用 LRU
+	expireMode lru.ExpireMode      // 命中时是否顺延过期时间
+	idle       time.Duration       // 滑动过期模式下的空闲超时
+	staleTTL   time.Duration       // 过期后继续保留旧值的时间，0 表示过期即删除
+	// onEviction 在键值对被淘汰、过期、删除或替换时调用，调用时持有分片的锁
+	onEviction func(key string, value *ByteView, reason lru.EvictReason)
+	evictions  atomic.Int64 // 因为内存不足或过期被淘汰的键值对个数
+
+	tagMu sync.Mutex
+	tags  map[string]map[string]struct{} // 标签到带有这个标签的键的反向索引
+
+	once   sync.Once
+	shards []*shard
+
+	stopOnce sync.Once
+	stop     chan struct{} // 关闭后 janitor 退出
+}
+
+// shard 是缓存的一个分片
+type shard struct {
+	mu  sync.Mutex
+	lru shardStore
+}
+
+// shardStore 是分片底层存储需要提供的操作，默认由 lru.Cache 实现，
+// 分片只通过这个接口访问底层存储，便于替换实现
+type shardStore interface {
+	Add(key string, value lru.Value, expire time.Time)
+	AddExact(key string, value lru.Value, expire time.Time)
+	Get(key string) (value lru.Value, ok bool)
+	GetStale(key string) (value lru.Value, stale bool, ok bool)
+	Peek(key string) (value lru.Value, ok bool)
+	Contains(key string) bool
+	Remove(key string)
+	Range(fn func(item lru.Item) bool)
+	Oldest() (item lru.Item, ok bool)
+	Newest() (item lru.Item, ok bool)
+	Len() int
+	Bytes() int64
+	Clear()
+	RemoveExpired() int
+}
+
+// policy 为底层的 lru.Cache 创建淘汰策略
+func (c *cache) policy() lru.Policy {
+	if c.newPolicy == nil {
+		return lru.NewLRU()
+	}
+	return c.newPolicy()
+}
+
+// init 按配置创建分片，cacheBytes 平均分给每个分片
+func (c *cache) init() {
+	c.once.Do(func() {
+		n := c.shardCount
+		if n <= 0 {
+			n = DefaultCacheShards
+		}
+		for n > 1 && c.cacheBytes/int64(n) < minShardBytes {
+			n /= 2
+		}
+		minBytes := c.minBytes
+		if minBytes <= 0 {
+			minBytes = lru.DefaultMaxBytes
+		}
+		shardBytes := c.cacheBytes / int64(n)
+		if minBytes > shardBytes {
+			shardBytes = minBytes
+		}
+		c.shards = make([]*shard, n)
+		for i := range c.shards {
+			l := lru.NewWithPolicy(shardBytes, c.policy(), nil)
+			l.ExpireRandom = c.jitter
+			l.ExpireMode = c.expireMode
+			l.IdleTimeout = c.idle
+			l.StaleTTL = c.staleTTL
+			l.OnEviction = func(key string, value lru.Value, reason lru.EvictReason) {
+				c.unindexTags(key, value.(*ByteView).tags)
+				if reason == lru.EvictCapacity || reason == lru.EvictExpired {
+					c.evictions.Add(1)
+				}
+				if c.onEviction != nil {
+					c.onEviction(key, value.(*ByteView), reason)
+				}
+			}
+			c.shards[i] = &shard{lru: l}
+		}
+	})
+}
+
+// shardFor 根据键的哈希值选择分片
+func (c *cache) shardFor(key string) *shard {
+	c.init()
+	if len(c.shards) == 1 {
+		return c.shards[0]
+	}
+	if c.hash != nil {
+		return c.shards[c.hash([]byte(key))%uint64(len(c.shards))]
+	}
+	return c.shards[fnv1.HashString64(key)%uint64(len(c.shards))]
 }
 
 // add 使用锁保证数据的一致性,底层调用lru的Add方法调整lru结构
 func (c *cache) add(key string, value *ByteView) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	if c.lru == nil {
-		if lru.DefaultMaxBytes > c.cacheBytes {
-			c.lru = lru.New(lru.DefaultMaxBytes, nil)
-		} else {
-			c.lru = lru.New(c.cacheBytes, nil)
+	s := c.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lru.Add(key, value, value.Expire())
+	if len(value.tags) > 0 && s.lru.Contains(key) {
+		c.indexTags(key, value.tags)
+	}
+}
+
+// addExact 与 add 相同，但不给过期时间加随机抖动，
+// 用于从快照中读回键值对和提升热点键这类已经确定了过期时间的场景
+func (c *cache) addExact(key string, value *ByteView) {
+	s := c.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lru.AddExact(key, value, value.Expire())
+	if len(value.tags) > 0 && s.lru.Contains(key) {
+		c.indexTags(key, value.tags)
+	}
+}
+
+// indexTags 把 key 加入它的每个标签的索引，调用时持有 key 所在分片的锁
+func (c *cache) indexTags(key string, tags []string) {
+	c.tagMu.Lock()
+	defer c.tagMu.Unlock()
+	if c.tags == nil {
+		c.tags = make(map[string]map[string]struct{})
+	}
+	for _, tag := range tags {
+		keys, ok := c.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			c.tags[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+}
+
+// unindexTags 把 key 从它的每个标签的索引中删除，调用时持有 key 所在分片的锁
+func (c *cache) unindexTags(key string, tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+	c.tagMu.Lock()
+	defer c.tagMu.Unlock()
+	for _, tag := range tags {
+		if keys, ok := c.tags[tag]; ok {
+			delete(keys, key)
+			if len(keys) == 0 {
+				delete(c.tags, tag)
+			}
 		}
 	}
-	c.lru.Add(key, value, value.Expire())
+}
+
+// removeTag 删除带有 tag 的所有键，返回删除的个数
+func (c *cache) removeTag(tag string) int {
+	c.tagMu.Lock()
+	keys := make([]string, 0, len(c.tags[tag]))
+	for key := range c.tags[tag] {
+		keys = append(keys, key)
+	}
+	c.tagMu.Unlock()
+	for _, key := range keys {
+		c.remove(key)
+	}
+	return len(keys)
 }
 
 // get 加锁,调用底层的Get
 func (c *cache) get(key string) (value *ByteView, ok bool) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	if c.lru == nil {
-		return
+	s := c.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v, ok := s.lru.Get(key); ok {
+		return v.(*ByteView), ok
 	}
-	if v, ok := c.lru.Get(key); ok {
+	return
+}
+
+// getStale 读取 key 对应的值，已经过期但还在保留期内的旧值也会被返回，stale 表示值是否已经过期
+func (c *cache) getStale(key string) (value *ByteView, stale bool, ok bool) {
+	s := c.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v, stale, ok := s.lru.GetStale(key); ok {
+		return v.(*ByteView), stale, ok
+	}
+	return
+}
+
+// remove 删除 key 对应的键值对
+func (c *cache) remove(key string) {
+	s := c.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lru.Remove(key)
+}
+
+// peek 读取 key 对应的值，但不改变访问顺序和过期时间
+func (c *cache) peek(key string) (value *ByteView, ok bool) {
+	s := c.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v, ok := s.lru.Peek(key); ok {
 		return v.(*ByteView), ok
 	}
 	return
 }
+
+// rangeItems 依次在每个分片的锁内遍历键值对，fn 返回 false 时停止遍历。
+// fn 中不能再访问这个缓存，否则会死锁
+func (c *cache) rangeItems(fn func(item lru.Item) bool) {
+	c.init()
+	for _, s := range c.shards {
+		stop := false
+		s.mu.Lock()
+		s.lru.Range(func(item lru.Item) bool {
+			stop = !fn(item)
+			return !stop
+		})
+		s.mu.Unlock()
+		if stop {
+			return
+		}
+	}
+}
+
+// oldest 返回所有分片中满足 keep 的最久未被访问的键值对
+func (c *cache) oldest(keep func(item lru.Item) bool) (item lru.Item, ok bool) {
+	return c.pick(func(s shardStore) (item lru.Item, ok bool) {
+		if item, ok = s.Oldest(); !ok || keep(item) {
+			return
+		}
+		// 最久未被访问的键值对不满足 keep 时，遍历整个分片找到最后一个满足的
+		ok = false
+		s.Range(func(it lru.Item) bool {
+			if keep(it) {
+				item, ok = it, true
+			}
+			return true
+		})
+		return
+	}, func(a, b lru.Item) bool { return a.Accessed.Before(b.Accessed) })
+}
+
+// newest 返回所有分片中满足 keep 的最近被访问的键值对
+func (c *cache) newest(keep func(item lru.Item) bool) (item lru.Item, ok bool) {
+	return c.pick(func(s shardStore) (item lru.Item, ok bool) {
+		s.Range(func(it lru.Item) bool {
+			if keep(it) {
+				item, ok = it, true
+			}
+			return !ok
+		})
+		return
+	}, func(a, b lru.Item) bool { return a.Accessed.After(b.Accessed) })
+}
+
+// pick 从每个分片中取出一个候选，再选出 better 意义下最好的一个
+func (c *cache) pick(get func(shardStore) (lru.Item, bool), better func(a, b lru.Item) bool) (item lru.Item, ok bool) {
+	c.init()
+	for _, s := range c.shards {
+		s.mu.Lock()
+		it, found := get(s.lru)
+		s.mu.Unlock()
+		if found && (!ok || better(it, item)) {
+			item, ok = it, true
+		}
+	}
+	return
+}
+
+// len 返回所有分片中键值对的总数
+func (c *cache) len() int {
+	c.init()
+	n := 0
+	for _, s := range c.shards {
+		s.mu.Lock()
+		n += s.lru.Len()
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// stats 返回缓存当前占用的内存、键值对个数和累计淘汰的个数
+func (c *cache) stats() CacheStats {
+	c.init()
+	st := CacheStats{Evictions: c.evictions.Load()}
+	for _, s := range c.shards {
+		s.mu.Lock()
+		st.Bytes += s.lru.Bytes()
+		st.Items += int64(s.lru.Len())
+		s.mu.Unlock()
+	}
+	return st
+}
+
+// clear 删除所有分片中的键值对
+func (c *cache) clear() {
+	c.init()
+	for _, s := range c.shards {
+		s.mu.Lock()
+		s.lru.Clear()
+		s.mu.Unlock()
+	}
+}
+
+// removeExpired 清理所有已经过期的键值对，返回清理的个数
+func (c *cache) removeExpired() int {
+	c.init()
+	n := 0
+	for _, s := range c.shards {
+		s.mu.Lock()
+		n += s.lru.RemoveExpired()
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// startJanitor 启动一个后台协程，每隔 interval 主动清理一次过期的键值对，
+// 避免不再被访问的过期数据一直占用内存。interval <= 0 时不启动。
+func (c *cache) startJanitor(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	c.stop = make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.removeExpired()
+			case <-c.stop:
+				return
+			}
+		}
+	}()
+}
+
+// stopJanitor 停止后台清理协程，可以重复调用
+func (c *cache) stopJanitor() {
+	c.stopOnce.Do(func() {
+		if c.stop != nil {
+			close(c.stop)
+		}
+	})
+}
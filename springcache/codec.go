@@ -0,0 +1,61 @@
+package springcache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec 负责在类型 T 和缓存中存储的字节之间转换
+type Codec[T any] interface {
+	Encode(v T) ([]byte, error)
+	Decode(data []byte) (T, error)
+}
+
+// JSONCodec 使用 encoding/json 编解码
+type JSONCodec[T any] struct{}
+
+func (JSONCodec[T]) Encode(v T) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec[T]) Decode(data []byte) (T, error) {
+	var v T
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+// GobCodec 使用 encoding/gob 编解码，每个值都单独编码，包含完整的类型信息
+type GobCodec[T any] struct{}
+
+func (GobCodec[T]) Encode(v T) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec[T]) Decode(data []byte) (T, error) {
+	var v T
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&v)
+	return v, err
+}
+
+// ProtoCodec 使用 protobuf 编解码，T 是生成的消息的指针类型，例如 *pb.GetRequest
+type ProtoCodec[T proto.Message] struct{}
+
+func (ProtoCodec[T]) Encode(v T) ([]byte, error) {
+	return proto.Marshal(v)
+}
+
+func (ProtoCodec[T]) Decode(data []byte) (T, error) {
+	var zero T
+	// 生成的消息类型的 nil 指针也可以取得消息的类型信息，用它创建新的消息
+	v := zero.ProtoReflect().Type().New().Interface().(T)
+	if err := proto.Unmarshal(data, v); err != nil {
+		return zero, err
+	}
+	return v, nil
+}
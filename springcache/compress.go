@@ -0,0 +1,129 @@
+package springcache
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// 值压缩：开启压缩的 Group 在值进入缓存时压缩超过阈值的值，ByteView 保存压缩后的字节，
+// 读取时再解压，缓存的内存统计使用压缩后的大小。
+// 压缩后的字节在节点之间原样传输，集群中同一个 Group 需要使用相同的压缩算法
+
+// DefaultCompressThreshold 是默认的压缩阈值，小于它的值不压缩
+var DefaultCompressThreshold = 1 << 10
+
+// Compressor 是可替换的压缩算法
+type Compressor interface {
+	Compress(b []byte) ([]byte, error)
+	Decompress(b []byte) ([]byte, error)
+}
+
+// FlateCompressor 使用 compress/flate 压缩，Level 为零值时使用 flate.DefaultCompression
+type FlateCompressor struct {
+	Level int
+}
+
+func (c FlateCompressor) Compress(b []byte) ([]byte, error) {
+	level := c.Level
+	if level == 0 {
+		level = flate.DefaultCompression
+	}
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c FlateCompressor) Decompress(b []byte) ([]byte, error) {
+	return io.ReadAll(flate.NewReader(bytes.NewReader(b)))
+}
+
+// GzipCompressor 使用 compress/gzip 压缩，比 flate 多了校验和，Level 为零值时使用 gzip.DefaultCompression
+type GzipCompressor struct {
+	Level int
+}
+
+func (c GzipCompressor) Compress(b []byte) ([]byte, error) {
+	level := c.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c GzipCompressor) Decompress(b []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// compress 返回 value 压缩后的 ByteView。没有开启压缩、值小于阈值、已经压缩过
+// 或者压缩后没有变小时原样返回
+func (g *Group) compress(value *ByteView) *ByteView {
+	if g.compressor == nil || value.zip != nil || len(value.b) < g.compressThreshold {
+		return value
+	}
+	b, err := g.compressor.Compress(value.b)
+	if err != nil {
+		g.logger.Warn("compress failed", "group", g.name, "err", err)
+		return value
+	}
+	if len(b) >= len(value.b) {
+		return value
+	}
+	v := *value
+	v.b, v.zip = b, g.compressor
+	return &v
+}
+
+// peerValue 是远端节点发来的一个值，pb.GetResponse、pb.SetRequest 和 pb.MultiItem 都实现了它
+type peerValue interface {
+	GetValue() []byte
+	GetExpire() int64
+	GetCompressed() bool
+	GetTags() []string
+}
+
+// peerView 把远端节点发来的值转换为 ByteView，压缩过的字节在读取时解压。
+// 压缩过的字节会先试着解压一次，两个节点的压缩算法不一致时返回错误，而不是在读取时得到空值
+func (g *Group) peerView(p peerValue) (*ByteView, error) {
+	v := &ByteView{b: p.GetValue(), e: unixToExpire(p.GetExpire()), tags: p.GetTags()}
+	if s, ok := p.(interface{ GetStale() bool }); ok {
+		v.stale = s.GetStale()
+	}
+	if p.GetCompressed() {
+		v.zip = g.compressor
+		if v.zip == nil {
+			v.zip = FlateCompressor{}
+		}
+		if _, err := v.decompress(); err != nil {
+			return nil, fmt.Errorf("springcache: decompress value from peer: %w", err)
+		}
+	}
+	return v, nil
+}
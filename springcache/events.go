@@ -0,0 +1,49 @@
+package springcache
+
+import "SpringCache/lru"
+
+// EvictionEvent 描述一个键值对离开缓存的事件
+type EvictionEvent struct {
+	Group  string
+	Cache  CacheType
+	Key    string
+	Value  *ByteView
+	Reason lru.EvictReason
+}
+
+// SubscribeEvictions 订阅 Group 中 mainCache 和 hotCache 的淘汰事件，
+// 可以用来统计失效指标或同步下游缓存，返回的函数用于取消订阅。
+// fn 在持有缓存分片锁的情况下被同步调用，不能在其中访问这个 Group，耗时的操作应当交给其他协程处理
+func (g *Group) SubscribeEvictions(fn func(EvictionEvent)) (unsubscribe func()) {
+	g.evictMu.Lock()
+	defer g.evictMu.Unlock()
+	if g.evictSubs == nil {
+		g.evictSubs = make(map[int]func(EvictionEvent))
+	}
+	id := g.nextSub
+	g.nextSub++
+	g.evictSubs[id] = fn
+	return func() {
+		g.evictMu.Lock()
+		defer g.evictMu.Unlock()
+		delete(g.evictSubs, id)
+	}
+}
+
+// evictionHook 返回 kind 对应缓存的淘汰回调，把事件分发给所有订阅者
+func (g *Group) evictionHook(kind CacheType) func(key string, value *ByteView, reason lru.EvictReason) {
+	return func(key string, value *ByteView, reason lru.EvictReason) {
+		if kind == MainCache && (reason == lru.EvictCapacity || reason == lru.EvictExpired) {
+			g.flushEvicted(key)
+		}
+		g.evictMu.RLock()
+		defer g.evictMu.RUnlock()
+		if len(g.evictSubs) == 0 {
+			return
+		}
+		ev := EvictionEvent{Group: g.name, Cache: kind, Key: key, Value: value, Reason: reason}
+		for _, fn := range g.evictSubs {
+			fn(ev)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package springcache
+
+// 代数：每个 Group 有一个代数，写入缓存的值都带着写入时的代数，
+// 读取时代数不等于当前代数的值被当作未命中并顺便删除。
+// 增加代数就能让整个 Group 的缓存立即失效，旧的值在被访问或被淘汰时才真正释放
+
+// Generation 返回 Group 当前的代数
+func (g *Group) Generation() uint64 {
+	return g.generation.Load()
+}
+
+// SetGeneration 把 Group 的代数设置为 gen，gen 不大于当前代数时什么也不做，返回代数是否变化。
+// 集群中应当通过 Server.BumpGeneration 修改代数，由每个节点监听 etcd 后调用它
+func (g *Group) SetGeneration(gen uint64) bool {
+	for {
+		cur := g.generation.Load()
+		if gen <= cur {
+			return false
+		}
+		if g.generation.CompareAndSwap(cur, gen) {
+			g.logger.Info("generation changed", "group", g.name, "generation", gen)
+			return true
+		}
+	}
+}
+
+// stamp 返回带有代数 gen 的 value 的副本
+func (g *Group) stamp(value *ByteView, gen uint64) *ByteView {
+	v := *value
+	v.gen = gen
+	return &v
+}
+
+// current 判断从 c 中读到的 key 的值是否属于当前代数，不属于时把它从 c 中删除
+func (g *Group) current(c *cache, key string, value *ByteView) bool {
+	if value.gen == g.generation.Load() {
+		return true
+	}
+	c.remove(key)
+	return false
+}
@@ -2,26 +2,64 @@ package springcache
 
 import (
 	"SpringCache/connect"
+	"SpringCache/logger"
+	"SpringCache/lru"
 	"SpringCache/singleflight"
+	"context"
 	"fmt"
 	"github.com/pkg/errors"
-	"log"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// DefaultExpireTime 是从数据源加载的值的默认存活时间，NewGroup 时读取，可以用 WithTTL 为每个 Group 单独设置
 var DefaultExpireTime = 30 * time.Second // 设置短过期时间用于测试
 
+// DefaultJanitorInterval 是后台清理过期缓存的默认间隔
+var DefaultJanitorInterval = time.Minute
+
 // Group 是 SpringCache 最核心的数据结构，负责与用户的交互，并且控制缓存值存储和获取的流程。
 type Group struct {
-	name      string
-	getter    Getter // 获取源数据的接口
-	mainCache cache  // 哈希算法本地存储的键值对
-	hotCache  cache  // 热点数据
-	peers     connect.PeerPicker
+	name        string
+	getter      ResultGetter // 获取源数据的接口
+	middlewares []Middleware // 包装 getter 的中间件，按注册的顺序从外到内
+	mainCache   cache        // 哈希算法本地存储的键值对
+	hotCache    cache        // 热点数据
+	negCache    cache        // 负缓存，记录数据源中不存在的键
+	peers       connect.PeerPicker
 	// use singleflight.Group to make sure that
 	// each key is only fetched once
 	loader *singleflight.Group // 用于控制并发问题
+
+	ttl             time.Duration // 从数据源加载的值的存活时间，<= 0 表示永不过期
+	negativeTTL     time.Duration // 负缓存的存活时间，<= 0 表示不使用负缓存
+	grace           time.Duration // 过期后仍可以返回旧值并在后台刷新的时间，<= 0 表示不返回旧值
+	refreshAhead    time.Duration // 命中时离过期不到这个时间就在后台提前刷新，<= 0 表示不提前刷新
+	staleIfError    time.Duration // 加载失败时可以返回过期不超过这个时间的旧值，<= 0 表示不返回旧值
+	janitorInterval time.Duration // 后台清理过期缓存的间隔，<= 0 表示不主动清理
+
+	hotKeys *hotKeys // 远端键的请求频率统计，为空时不自动识别热点
+	stats   groupStats
+	logger  logger.Logger // 日志，默认不输出
+
+	generation atomic.Uint64 // 当前代数，缓存中代数不同的值都已经失效
+
+	compressor        Compressor // 值的压缩算法，为空时不压缩
+	compressThreshold int        // 小于这个长度的值不压缩
+
+	writeMode WriteMode    // Set 和 Remove 如何写回数据源
+	setter    Setter       // 写数据源的接口
+	deleter   Deleter      // 删除数据源中的键的接口，为空时 Remove 只删除缓存
+	writer    *writeBehind // write-behind 模式下等待写回的队列
+
+	refreshMu  sync.Mutex
+	refreshing map[string]struct{} // 正在后台刷新的键
+
+	evictMu   sync.RWMutex
+	evictSubs map[int]func(EvictionEvent) // 淘汰事件的订阅者
+	nextSub   int
 }
 
 var (
@@ -29,21 +67,116 @@ var (
 	groups = make(map[string]*Group) // 全局变量groups，里面记录了已经创建的group
 )
 
-func NewGroup(name string, cacheBytes int64, hotcacheBytes int64, getter Getter) *Group {
+// ErrGroupExists 表示同名的 Group 已经存在，需要先 DeleteGroup 或 Close 才能重新创建
+var ErrGroupExists = errors.New("springcache: group already exists")
+
+// NewGroup 创建并注册一个 Group，同名的 Group 已经存在时返回 ErrGroupExists
+func NewGroup(name string, cacheBytes int64, hotcacheBytes int64, getter Getter, opts ...GroupOption) (*Group, error) {
+	if getter == nil {
+		panic("springcache: getter is nil")
+	}
+	return NewGroupContext(name, cacheBytes, hotcacheBytes, getterAdapter{getter}, opts...)
+}
+
+// NewGroupContext 与 NewGroup 相同，但使用感知 ctx 的 ContextGetter 获取源数据
+func NewGroupContext(name string, cacheBytes int64, hotcacheBytes int64, getter ContextGetter, opts ...GroupOption) (*Group, error) {
+	if getter == nil {
+		panic("springcache: getter is nil")
+	}
+	return NewGroupResult(name, cacheBytes, hotcacheBytes, contextGetterAdapter{getter}, opts...)
+}
+
+// NewGroupResult 与 NewGroup 相同，但使用 ResultGetter 获取源数据，数据源可以为每个键指定过期时间
+func NewGroupResult(name string, cacheBytes int64, hotcacheBytes int64, getter ResultGetter, opts ...GroupOption) (*Group, error) {
 	if getter == nil {
 		panic("springcache: getter is nil")
 	}
 	mu.Lock()
 	defer mu.Unlock()
+	if _, ok := groups[name]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrGroupExists, name)
+	}
 	g := &Group{
 		name:      name,
 		getter:    getter,
-		mainCache: cache{cacheBytes: cacheBytes},
-		hotCache:  cache{cacheBytes: hotcacheBytes},
+		mainCache: cache{cacheBytes: cacheBytes, minBytes: lru.DefaultMaxBytes, jitter: lru.DefaultExpireRandom},
+		hotCache:  cache{cacheBytes: hotcacheBytes, minBytes: lru.DefaultMaxBytes, jitter: lru.DefaultExpireRandom},
+		negCache:  cache{cacheBytes: DefaultNegativeCacheBytes, minBytes: lru.DefaultMaxBytes},
 		loader:    &singleflight.Group{},
+
+		ttl:             DefaultExpireTime,
+		negativeTTL:     DefaultNegativeTTL,
+		janitorInterval: DefaultJanitorInterval,
+		refreshing:      make(map[string]struct{}),
+		logger:          logger.Discard,
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	g.getter = chainMiddleware(g.getter, g.middlewares)
+	g.mainCache.staleTTL = g.grace
+	if g.staleIfError > g.grace {
+		g.mainCache.staleTTL = g.staleIfError
 	}
+	g.hotCache.staleTTL = g.staleIfError
+	g.mainCache.onEviction = g.evictionHook(MainCache)
+	g.hotCache.onEviction = g.evictionHook(HotCache)
+	if g.writer != nil {
+		g.writer.logger = g.logger
+		go g.writer.run()
+	}
+	g.mainCache.startJanitor(g.janitorInterval)
+	g.hotCache.startJanitor(g.janitorInterval)
+	g.negCache.startJanitor(g.janitorInterval)
 	groups[name] = g
-	return g
+	return g, nil
+}
+
+// Stop 停止 Group 的后台清理协程，可以重复调用
+func (g *Group) Stop() {
+	g.mainCache.stopJanitor()
+	g.hotCache.stopJanitor()
+	g.negCache.stopJanitor()
+}
+
+// Close 停止 Group 的后台协程，写回 write-behind 队列中剩下的操作，
+// 清空所有缓存并把它从全局注册表中删除，之后可以用同样的名字重新创建。
+// 可以重复调用，Close 之后不应该再使用这个 Group
+func (g *Group) Close() {
+	mu.Lock()
+	if groups[g.name] == g {
+		delete(groups, g.name)
+	}
+	mu.Unlock()
+	g.Stop()
+	if g.writer != nil {
+		g.writer.close()
+	}
+	g.mainCache.clear()
+	g.hotCache.clear()
+	g.negCache.clear()
+}
+
+// DeleteGroup 关闭并删除名为 name 的 Group，Group 不存在时返回 false
+func DeleteGroup(name string) bool {
+	g := GetGroup(name)
+	if g == nil {
+		return false
+	}
+	g.Close()
+	return true
+}
+
+// ListGroups 按名字排序返回所有已经注册的 Group 的名字
+func ListGroups() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
 func (g *Group) RegisterPeers(peers connect.PeerPicker) {
@@ -61,99 +194,224 @@ func GetGroup(name string) *Group {
 }
 
 func (g *Group) Get(key string) (*ByteView, error) {
+	return g.GetContext(context.Background(), key)
+}
+
+// GetContext 与 Get 相同，但 ctx 的超时和取消会传递给远端节点和数据源
+func (g *Group) GetContext(ctx context.Context, key string) (*ByteView, error) {
 	if key == "" {
 		return &ByteView{}, fmt.Errorf("springcache: key is empty")
 	}
 	if v, ok := g.lookupCache(key); ok {
-		log.Println("SpringCache hit")
+		g.logger.Debug("cache hit", "group", g.name, "key", key)
+		g.stats.recordGet(true)
+		return v, nil
+	}
+	if g.lookupNegative(key) {
+		g.stats.recordGet(true)
+		return nil, ErrNotFound
+	}
+	if v, ok := g.lookupStale(key); ok {
+		g.stats.recordGet(true)
 		return v, nil
 	}
-	log.Println("SpringCache miss, try to add it")
-	return g.Load(key)
+	g.logger.Debug("cache miss", "group", g.name, "key", key)
+	g.stats.recordGet(false)
+	return g.LoadContext(ctx, key)
 }
 
 // 如果缓存没有命中，则将去远程节点进行查询或查询数据库
 // Load loads key either by invoking the getter locally or by sending it to another machine.
 func (g *Group) Load(key string) (value *ByteView, err error) {
+	return g.LoadContext(context.Background(), key)
+}
+
+// LoadContext 与 Load 相同，ctx 被取消或超时后立即返回 ctx.Err()
+func (g *Group) LoadContext(ctx context.Context, key string) (value *ByteView, err error) {
 	// 用Do函数封装实际的load操作，保证并发性
-	view, err := g.loader.DoOnce(key, func() (interface{}, error) {
+	view, err, shared := g.loader.DoContextShared(ctx, key, func(ctx context.Context) (interface{}, error) {
 		if g.peers != nil {
-			log.Println("try to search from peers")
 			if peer, ok := g.peers.PickPeer(key); ok {
-				if value, err = g.getFromPeer(peer, key); err != nil {
-					log.Println("springcache: get from peer error:", err)
+				value, err := g.getFromPeer(ctx, peer, key)
+				if err != nil {
+					if isNotFound(err) {
+						return nil, ErrNotFound
+					}
+					g.logger.Warn("get from peer failed", "group", g.name, "key", key, "err", err)
+					g.stats.peerErrors.Add(1)
 					return nil, err
 				}
+				g.stats.peerLoads.Add(1)
+				g.recordPeerLoad(key, value)
 				return value, nil
 			}
 		}
-		return g.getLocally(key)
+		return g.getLocally(ctx, key)
 	})
+	if shared {
+		g.stats.dedups.Add(1)
+	}
 	if err == nil {
 		return view.(*ByteView), nil
 	}
+	if v, ok := g.staleOnError(ctx, key, err); ok {
+		return v, nil
+	}
 	return
 }
 
-func (g *Group) getFromPeer(peer connect.PeerGetter, key string) (*ByteView, error) {
-	bytes, err := peer.Get(g.name, key)
+func (g *Group) getFromPeer(ctx context.Context, peer connect.PeerGetter, key string) (*ByteView, error) {
+	resp, err := peer.Get(ctx, g.name, key)
 	if err != nil {
 		return nil, err
 	}
-	return &ByteView{b: bytes}, nil
+	return g.peerView(resp)
 }
 
 // 在数据库中查到数据后，添加到缓存中
-func (g *Group) getLocally(key string) (*ByteView, error) {
+func (g *Group) getLocally(ctx context.Context, key string) (*ByteView, error) {
+	// 在加载前记下代数，加载期间代数增加时，这次加载的值不会在新的代数中可见
+	gen := g.Generation()
+	if g.writer != nil {
+		// 还没有写回完成的键在数据源中可能还是旧值，直接使用等待写回的值
+		if op, ok := g.writer.lookup(key); ok {
+			return g.loadPending(key, op, gen)
+		}
+	}
 	// 这里调用的是创建Group时存储的getter函数
-	bytes, err := g.getter.Get(key)
+	res, err := g.getter.GetResult(ctx, key)
 	if err != nil {
+		if isNotFound(err) {
+			g.populateNegative(key, gen)
+		}
 		return &ByteView{}, err
 	}
-	value := &ByteView{b: cloneBytes(bytes), e: time.Now().Add(DefaultExpireTime)}
-	g.populateCache(key, value)
+	g.stats.localLoads.Add(1)
+	value := g.compress(&ByteView{b: cloneBytes(res.Value), e: g.resultExpire(res, time.Now()), tags: res.Tags})
+	if !res.NoCache {
+		g.populate(key, value, gen)
+	}
 	return value, nil
 }
 
-// populateCache 将源数据添加到缓存 mainCache
+// loadPending 用 write-behind 队列中等待写回的操作 op 作为 key 的加载结果
+func (g *Group) loadPending(key string, op *pendingWrite, gen uint64) (*ByteView, error) {
+	if op.deleted {
+		return &ByteView{}, ErrNotFound
+	}
+	value := g.compress(&ByteView{b: cloneBytes(op.value), e: g.resultExpire(Result{}, time.Now())})
+	g.populate(key, value, gen)
+	return value, nil
+}
+
+// resultExpire 返回数据源在 now 时加载的值的过期时间，永不过期时返回零值。
+// 数据源指定的过期时间优先于 Group 的 TTL
+func (g *Group) resultExpire(res Result, now time.Time) time.Time {
+	switch {
+	case !res.Expire.IsZero():
+		return res.Expire
+	case res.TTL > 0:
+		return now.Add(res.TTL)
+	case g.ttl > 0:
+		return now.Add(g.ttl)
+	}
+	return time.Time{}
+}
+
+// expireToUnix 把过期时间转换为 rpc 中使用的 unix 秒，永不过期时为 0
+func expireToUnix(expire time.Time) int64 {
+	if expire.IsZero() {
+		return 0
+	}
+	return expire.Unix()
+}
+
+// unixToExpire 把 rpc 中的 unix 秒转换为过期时间，<= 0 表示永不过期
+func unixToExpire(sec int64) time.Time {
+	if sec <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
+
+// populateCache 将源数据以当前代数添加到缓存 mainCache，并清除负缓存中的记录
 func (g *Group) populateCache(key string, value *ByteView) {
-	g.mainCache.add(key, value)
+	g.populate(key, value, g.Generation())
+}
+
+// populate 将值以代数 gen 添加到缓存 mainCache
+func (g *Group) populate(key string, value *ByteView, gen uint64) {
+	g.negCache.remove(key)
+	g.mainCache.add(key, g.stamp(g.compress(value), gen))
 }
 
 func (g *Group) lookupCache(key string) (value *ByteView, ok bool) {
 	value, ok = g.mainCache.get(key)
-	if ok {
-		return
+	if ok && g.current(&g.mainCache, key, value) {
+		g.stats.mainCacheHits.Add(1)
+		g.maybeRefreshAhead(key, value)
+		return value, true
 	}
 	value, ok = g.hotCache.get(key)
-	return
+	if ok && g.current(&g.hotCache, key, value) {
+		g.stats.hotCacheHits.Add(1)
+		return value, true
+	}
+	return nil, false
 }
 
-func (g *Group) Set(key string, value *ByteView, ishot bool) error {
+// Set 设置 key 对应的缓存，ishot 为 true 时只设置本地的 hotCache，tags 是值的标签。
+// 设置了 Setter 时，非热点的值还会按写模式写回数据源，write-through 模式下写数据源失败时不修改缓存
+func (g *Group) Set(key string, value *ByteView, ishot bool, tags ...string) error {
+	return g.SetContext(context.Background(), key, value, ishot, tags...)
+}
+
+// SetContext 与 Set 相同，ctx 用于控制 write-through 写数据源和对远端节点的请求
+func (g *Group) SetContext(ctx context.Context, key string, value *ByteView, ishot bool, tags ...string) error {
 	if key == "" {
 		return errors.New("key is empty")
 	}
+	if len(tags) > 0 {
+		v := *value
+		v.tags = append([]string(nil), tags...)
+		value = &v
+	}
 	if ishot {
 		return g.setHotCache(key, value)
 	}
-	_, err := g.loader.DoOnce(key, func() (interface{}, error) {
-		if peer, ok := g.peers.PickPeer(key); ok {
-			err := g.setFromPeer(peer, key, value, ishot)
-			if err != nil {
-				log.Println("springcache: set from peer error:", err)
-				return nil, err
+	if err := g.writeStore(ctx, key, value); err != nil {
+		return err
+	}
+	return g.setCache(ctx, key, value)
+}
+
+// setCache 只设置缓存，不写数据源，远端节点转发过来的 Set 直接调用它
+func (g *Group) setCache(ctx context.Context, key string, value *ByteView) error {
+	if key == "" {
+		return errors.New("key is empty")
+	}
+	_, err := g.loader.DoContext(ctx, key, func(ctx context.Context) (interface{}, error) {
+		if g.peers != nil {
+			if peer, ok := g.peers.PickPeer(key); ok {
+				err := g.setFromPeer(ctx, peer, key, value, false)
+				if err != nil {
+					g.logger.Warn("set on peer failed", "group", g.name, "key", key, "err", err)
+					return nil, err
+				}
+				return value, nil
 			}
-			return value, nil
 		}
 		// 如果 ！ok，则说明选择到当前节点
-		g.mainCache.add(key, value)
+		g.populateCache(key, value)
 		return value, nil
 	})
 	return err
 }
 
-func (g *Group) setFromPeer(peer connect.PeerGetter, key string, value *ByteView, ishot bool) error {
-	return peer.Set(g.name, key, value.ByteSlice(), value.Expire(), ishot)
+// setFromPeer 让远端节点设置缓存，压缩过的值原样发送，由接收方解压
+func (g *Group) setFromPeer(ctx context.Context, peer connect.PeerGetter, key string, value *ByteView, ishot bool) error {
+	v := g.compress(value)
+	return peer.Set(ctx, g.name, key, v.b, v.Compressed(), v.Expire(), ishot, v.tags)
 }
 
 // setHotCache 设置热点缓存
@@ -162,9 +420,43 @@ func (g *Group) setHotCache(key string, value *ByteView) error {
 		return errors.New("key is empty")
 	}
 	g.loader.DoOnce(key, func() (interface{}, error) {
-		g.hotCache.add(key, value)
-		log.Printf("SpringCache set hot cache %v \n", value.ByteSlice())
+		g.hotCache.add(key, g.stamp(g.compress(value), g.Generation()))
+		g.logger.Debug("set hot cache", "group", g.name, "key", key)
 		return nil, nil
 	})
 	return nil
 }
+
+// Remove 删除 key 对应的缓存，数据库中的数据被修改后可以调用它让缓存立即失效。
+// 本地的 mainCache 和 hotCache 副本会被删除，并且请求 key 所属的远端节点删除它的缓存
+func (g *Group) Remove(key string) error {
+	return g.RemoveContext(context.Background(), key)
+}
+
+// RemoveContext 与 Remove 相同，ctx 用于控制对远端节点的请求。
+// 设置了 Deleter 时还会按写模式从数据源中删除 key
+func (g *Group) RemoveContext(ctx context.Context, key string) error {
+	if key == "" {
+		return errors.New("key is empty")
+	}
+	if err := g.deleteStore(ctx, key); err != nil {
+		return err
+	}
+	g.removeLocally(key)
+	if g.peers != nil {
+		if peer, ok := g.peers.PickPeer(key); ok {
+			if err := peer.Delete(ctx, g.name, key); err != nil {
+				g.logger.Warn("delete from peer failed", "group", g.name, "key", key, "err", err)
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// removeLocally 只删除本地 mainCache、hotCache 和负缓存中的 key
+func (g *Group) removeLocally(key string) {
+	g.mainCache.remove(key)
+	g.hotCache.remove(key)
+	g.negCache.remove(key)
+}
@@ -0,0 +1,54 @@
+package springcache
+
+import (
+	"SpringCache/sketch"
+	"sync"
+	"time"
+)
+
+// 热点键检测：用 Count-Min Sketch 近似统计向远端节点请求每个键的频率，
+// 频率超过阈值的键被复制到本地的 hotCache 中，存活时间受 ttl 限制，
+// 这样单个热点键不会把请求都压到它所属的节点上
+
+// DefaultHotKeyWidth 是热点键统计使用的 sketch 每行的计数器个数
+var DefaultHotKeyWidth = 1 << 12
+
+// hotKeys 统计远端键的请求频率，可以被并发访问
+type hotKeys struct {
+	mu        sync.Mutex
+	sketch    *sketch.CountMin
+	threshold int           // 频率达到这个值的键被认为是热点
+	ttl       time.Duration // 热点键在 hotCache 中的最长存活时间
+}
+
+func newHotKeys(threshold int, ttl time.Duration) *hotKeys {
+	return &hotKeys{
+		sketch:    sketch.New(DefaultHotKeyWidth),
+		threshold: threshold,
+		ttl:       ttl,
+	}
+}
+
+// record 记录一次对 key 的请求，返回 key 是否已经成为热点
+func (h *hotKeys) record(key string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sketch.Increment(key)
+	return h.sketch.Estimate(key) >= h.threshold
+}
+
+// recordPeerLoad 记录一次从远端节点加载 key，key 成为热点时把值放入 hotCache。
+// 放入的值在 ttl 后过期，如果值本身更早过期则使用值的过期时间
+func (g *Group) recordPeerLoad(key string, value *ByteView) {
+	if g.hotKeys == nil || value.Stale() || !g.hotKeys.record(key) {
+		return
+	}
+	expire := time.Now().Add(g.hotKeys.ttl)
+	if !value.Expire().IsZero() && value.Expire().Before(expire) {
+		expire = value.Expire()
+	}
+	v := *value
+	v.e, v.gen = expire, g.Generation()
+	// 过期时间已经限制在 ttl 之内，不再加随机抖动
+	g.hotCache.addExact(key, &v)
+}
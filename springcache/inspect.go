@@ -0,0 +1,103 @@
+package springcache
+
+import "SpringCache/lru"
+
+// 这里提供只读的查看接口，供管理工具抽样和列出缓存内容。
+// 它们不会改变键的访问顺序，也不会顺延滑动过期时间。
+
+// CacheType 表示 Group 中的一种缓存
+type CacheType int
+
+const (
+	// MainCache 保存由当前节点负责的键
+	MainCache CacheType = iota + 1
+	// HotCache 保存热点数据
+	HotCache
+)
+
+// cache 返回 kind 对应的缓存，kind 无效时返回 nil
+func (g *Group) cache(kind CacheType) *cache {
+	switch kind {
+	case MainCache:
+		return &g.mainCache
+	case HotCache:
+		return &g.hotCache
+	}
+	return nil
+}
+
+// Peek 依次在 mainCache 和 hotCache 中查找 key，不会触发加载
+func (g *Group) Peek(key string) (*ByteView, bool) {
+	if v, ok := g.mainCache.peek(key); ok && v.gen == g.Generation() {
+		return v, true
+	}
+	if v, ok := g.hotCache.peek(key); ok && v.gen == g.Generation() {
+		return v, true
+	}
+	return nil, false
+}
+
+// Contains 判断 key 是否在本地缓存中
+func (g *Group) Contains(key string) bool {
+	_, ok := g.Peek(key)
+	return ok
+}
+
+// Keys 返回 kind 对应缓存中的所有键，每个分片内按从最近访问到最久未访问的顺序排列
+func (g *Group) Keys(kind CacheType) []string {
+	var keys []string
+	g.Range(kind, func(key string, _ *ByteView) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys
+}
+
+// Range 遍历 kind 对应缓存中的键值对，fn 返回 false 时停止遍历。
+// 已经因为代数增加而失效的键值对会被跳过。
+// 遍历时持有缓存分片的锁，fn 中不能再调用这个 Group 的方法
+func (g *Group) Range(kind CacheType, fn func(key string, value *ByteView) bool) {
+	c := g.cache(kind)
+	if c == nil {
+		return
+	}
+	gen := g.Generation()
+	c.rangeItems(func(item lru.Item) bool {
+		v := item.Value.(*ByteView)
+		if v.gen != gen {
+			return true
+		}
+		return fn(item.Key, v)
+	})
+}
+
+// Oldest 返回 kind 对应缓存中最久未被访问的键值对，跳过已经因为代数增加而失效的键值对
+func (g *Group) Oldest(kind CacheType) (key string, value *ByteView, ok bool) {
+	if c := g.cache(kind); c != nil {
+		return itemView(c.oldest(g.currentItem()))
+	}
+	return
+}
+
+// Newest 返回 kind 对应缓存中最近被访问的键值对，跳过已经因为代数增加而失效的键值对
+func (g *Group) Newest(kind CacheType) (key string, value *ByteView, ok bool) {
+	if c := g.cache(kind); c != nil {
+		return itemView(c.newest(g.currentItem()))
+	}
+	return
+}
+
+// currentItem 返回一个判断键值对是否属于当前代数的函数
+func (g *Group) currentItem() func(item lru.Item) bool {
+	gen := g.Generation()
+	return func(item lru.Item) bool {
+		return item.Value.(*ByteView).gen == gen
+	}
+}
+
+func itemView(item lru.Item, ok bool) (string, *ByteView, bool) {
+	if !ok {
+		return "", nil, false
+	}
+	return item.Key, item.Value.(*ByteView), true
+}
@@ -0,0 +1,40 @@
+package springcache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Middleware 包装数据源，可以在加载源数据的前后加入计时、重试、追踪或改写键等逻辑。
+// 用 WithMiddleware 注册到 Group 上，只作用于本节点调用数据源的路径，从远端节点加载时不经过它
+type Middleware func(next ResultGetter) ResultGetter
+
+// chainMiddleware 用 mws 包装 getter，mws[0] 在最外层，最先看到请求
+func chainMiddleware(getter ResultGetter, mws []Middleware) ResultGetter {
+	for i := len(mws) - 1; i >= 0; i-- {
+		getter = mws[i](getter)
+	}
+	return getter
+}
+
+// RetryMiddleware 在数据源返回错误时最多再重试 retries 次，每次重试前等待 backoff，
+// 等待时间每次翻倍。ErrNotFound 以及 ctx 结束导致的错误不会重试
+func RetryMiddleware(retries int, backoff time.Duration) Middleware {
+	return func(next ResultGetter) ResultGetter {
+		return ResultGetterFunc(func(ctx context.Context, key string) (Result, error) {
+			res, err := next.GetResult(ctx, key)
+			for i := 0; i < retries && err != nil && !isNotFound(err) && ctx.Err() == nil; i++ {
+				timer := time.NewTimer(backoff << i)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return res, errors.Join(err, ctx.Err())
+				case <-timer.C:
+				}
+				res, err = next.GetResult(ctx, key)
+			}
+			return res, err
+		})
+	}
+}
@@ -0,0 +1,283 @@
+package springcache
+
+import (
+	"SpringCache/connect"
+	pb "SpringCache/springcachepb"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// 批量操作：按一致性哈希把键分给所属节点，每个远端节点只发送一次 rpc 请求，
+// 属于本节点的键在一次遍历中完成加载
+
+// multiResult 收集批量操作中每个键的结果，可以被多个协程并发写入
+type multiResult struct {
+	mu     sync.Mutex
+	values map[string]*ByteView
+	errs   map[string]error
+}
+
+func newMultiResult() *multiResult {
+	return &multiResult{
+		values: make(map[string]*ByteView),
+		errs:   make(map[string]error),
+	}
+}
+
+func (r *multiResult) set(key string, value *ByteView, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.errs[key] = err
+		return
+	}
+	if value != nil {
+		r.values[key] = value
+	}
+}
+
+// splitByOwner 把键按所属节点分组，属于本节点的键放在 local 中
+func (g *Group) splitByOwner(keys []string) (remote map[connect.PeerGetter][]string, local []string) {
+	remote = make(map[connect.PeerGetter][]string)
+	for _, key := range keys {
+		if g.peers != nil {
+			if peer, ok := g.peers.PickPeer(key); ok {
+				remote[peer] = append(remote[peer], key)
+				continue
+			}
+		}
+		local = append(local, key)
+	}
+	return
+}
+
+// GetMulti 批量获取多个键，返回获取成功的值和每个失败的键对应的错误
+func (g *Group) GetMulti(ctx context.Context, keys []string) (map[string]*ByteView, map[string]error) {
+	return g.getMulti(ctx, keys, true)
+}
+
+// getMulti 批量获取多个键，forward 为 false 时所有的键都在本节点加载，不再转发给其他节点。
+// 远端节点转发过来的 GetMulti 使用 false，避免哈希环不一致时请求在节点之间来回转发
+func (g *Group) getMulti(ctx context.Context, keys []string, forward bool) (map[string]*ByteView, map[string]error) {
+	res := newMultiResult()
+	var misses []string
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if key == "" {
+			res.set(key, nil, errors.New("springcache: key is empty"))
+			continue
+		}
+		if v, ok := g.lookupCache(key); ok {
+			g.stats.recordGet(true)
+			res.set(key, v, nil)
+			continue
+		}
+		if g.lookupNegative(key) {
+			g.stats.recordGet(true)
+			res.set(key, nil, ErrNotFound)
+			continue
+		}
+		if v, ok := g.lookupStale(key); ok {
+			g.stats.recordGet(true)
+			res.set(key, v, nil)
+			continue
+		}
+		g.stats.recordGet(false)
+		misses = append(misses, key)
+	}
+
+	remote, local := map[connect.PeerGetter][]string(nil), misses
+	if forward {
+		remote, local = g.splitByOwner(misses)
+	}
+	var wg sync.WaitGroup
+	for peer, peerKeys := range remote {
+		wg.Add(1)
+		go func(peer connect.PeerGetter, peerKeys []string) {
+			defer wg.Done()
+			g.getMultiFromPeer(ctx, peer, peerKeys, res)
+		}(peer, peerKeys)
+	}
+	for _, key := range local {
+		view, err, shared := g.loader.DoContextShared(ctx, key, func(ctx context.Context) (interface{}, error) {
+			return g.getLocally(ctx, key)
+		})
+		if shared {
+			g.stats.dedups.Add(1)
+		}
+		if err != nil {
+			if v, ok := g.staleOnError(ctx, key, err); ok {
+				res.set(key, v, nil)
+				continue
+			}
+			res.set(key, nil, err)
+			continue
+		}
+		res.set(key, view.(*ByteView), nil)
+	}
+	wg.Wait()
+	return res.values, res.errs
+}
+
+func (g *Group) getMultiFromPeer(ctx context.Context, peer connect.PeerGetter, keys []string, res *multiResult) {
+	items, err := peer.GetMulti(ctx, g.name, keys)
+	if err != nil {
+		g.logger.Warn("get multi from peer failed", "group", g.name, "keys", len(keys), "err", err)
+		g.stats.peerErrors.Add(1)
+		for _, key := range keys {
+			res.set(key, nil, err)
+		}
+		return
+	}
+	missing := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		missing[key] = true
+	}
+	for _, item := range items {
+		if !missing[item.GetKey()] {
+			continue
+		}
+		delete(missing, item.GetKey())
+		if item.GetNotFound() {
+			res.set(item.GetKey(), nil, ErrNotFound)
+			continue
+		}
+		if item.GetError() != "" {
+			res.set(item.GetKey(), nil, errors.New(item.GetError()))
+			continue
+		}
+		value, err := g.peerView(item)
+		if err != nil {
+			g.stats.peerErrors.Add(1)
+			res.set(item.GetKey(), nil, err)
+			continue
+		}
+		g.stats.peerLoads.Add(1)
+		g.recordPeerLoad(item.GetKey(), value)
+		res.set(item.GetKey(), value, nil)
+	}
+	// 远端节点没有返回的键也要有一个错误，调用者才能区分它们和成功的键
+	for key := range missing {
+		res.set(key, nil, fmt.Errorf("springcache: peer returned no result for %s/%s", g.name, key))
+	}
+}
+
+// SetMulti 批量设置多个键，返回每个设置失败的键对应的错误。
+// 与 Set 一样，非热点的值会按写模式写回数据源，写数据源失败的键不会被缓存
+func (g *Group) SetMulti(ctx context.Context, values map[string]*ByteView, ishot bool) map[string]error {
+	if ishot {
+		return g.setMultiCache(ctx, values, ishot)
+	}
+	accepted := make(map[string]*ByteView, len(values))
+	errs := make(map[string]error)
+	for key, value := range values {
+		if key != "" {
+			if err := g.writeStore(ctx, key, value); err != nil {
+				errs[key] = err
+				continue
+			}
+		}
+		accepted[key] = value
+	}
+	for key, err := range g.setMultiCache(ctx, accepted, ishot) {
+		errs[key] = err
+	}
+	return errs
+}
+
+// setMultiCache 只批量设置缓存，不写数据源，属于其他节点的键转发给所属节点
+func (g *Group) setMultiCache(ctx context.Context, values map[string]*ByteView, ishot bool) map[string]error {
+	res := newMultiResult()
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		if key == "" {
+			res.set(key, nil, errors.New("key is empty"))
+			continue
+		}
+		keys = append(keys, key)
+	}
+	if ishot {
+		for _, key := range keys {
+			g.hotCache.add(key, g.stamp(g.compress(values[key]), g.Generation()))
+		}
+		return res.errs
+	}
+
+	remote, local := g.splitByOwner(keys)
+	var wg sync.WaitGroup
+	for peer, peerKeys := range remote {
+		items := make([]*pb.MultiItem, 0, len(peerKeys))
+		for _, key := range peerKeys {
+			items = append(items, viewToMultiItem(key, g.compress(values[key])))
+		}
+		wg.Add(1)
+		go func(peer connect.PeerGetter, items []*pb.MultiItem) {
+			defer wg.Done()
+			failed, err := peer.SetMulti(ctx, g.name, items, ishot)
+			if err != nil {
+				g.logger.Warn("set multi on peer failed", "group", g.name, "keys", len(items), "err", err)
+				for _, item := range items {
+					res.set(item.GetKey(), nil, err)
+				}
+				return
+			}
+			for _, item := range failed {
+				res.set(item.GetKey(), nil, errors.New(item.GetError()))
+			}
+		}(peer, items)
+	}
+	for _, key := range local {
+		g.populateCache(key, values[key])
+	}
+	wg.Wait()
+	return res.errs
+}
+
+// setMultiLocally 只在本节点批量设置缓存，不写数据源也不转发，远端节点转发过来的 SetMulti 直接调用它
+func (g *Group) setMultiLocally(values map[string]*ByteView, ishot bool) map[string]error {
+	errs := make(map[string]error)
+	for key, value := range values {
+		switch {
+		case key == "":
+			errs[key] = errors.New("key is empty")
+		case ishot:
+			g.hotCache.add(key, g.stamp(g.compress(value), g.Generation()))
+		default:
+			g.populateCache(key, value)
+		}
+	}
+	return errs
+}
+
+// multiItemsToViews 把 rpc 请求中的键值对转换为 ByteView，无法解压的键放在 errs 中
+func (g *Group) multiItemsToViews(items []*pb.MultiItem) (values map[string]*ByteView, errs map[string]error) {
+	values = make(map[string]*ByteView, len(items))
+	errs = make(map[string]error)
+	for _, item := range items {
+		v, err := g.peerView(item)
+		if err != nil {
+			errs[item.GetKey()] = err
+			continue
+		}
+		values[item.GetKey()] = v
+	}
+	return values, errs
+}
+
+// viewToMultiItem 把 ByteView 转换为 rpc 中的键值对，压缩过的值原样发送
+func viewToMultiItem(key string, v *ByteView) *pb.MultiItem {
+	return &pb.MultiItem{
+		Key:        key,
+		Value:      v.b,
+		Expire:     expireToUnix(v.Expire()),
+		Stale:      v.Stale(),
+		Compressed: v.Compressed(),
+		Tags:       v.tags,
+	}
+}
@@ -0,0 +1,77 @@
+package springcache
+
+import (
+	"errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+// 负缓存：数据源返回 ErrNotFound 时，把"键不存在"这个结果也缓存一小段时间，
+// 避免对不存在的键的重复请求每次都打到数据库上(缓存穿透)
+
+// ErrNotFound 表示数据源中不存在这个键。
+// Getter 可以返回它(或包装了它的错误)，Group 会把这个结果缓存到负缓存中
+var ErrNotFound = errors.New("springcache: key not found")
+
+var (
+	// DefaultNegativeTTL 是负缓存的默认存活时间
+	DefaultNegativeTTL = 5 * time.Second
+	// DefaultNegativeCacheBytes 是负缓存默认的最大内存
+	DefaultNegativeCacheBytes int64 = 1 << 16
+)
+
+// notFoundReason 是 toStatus 附加在 NotFound 状态上的原因，
+// 用于区分"键不存在"和"节点上没有这个 Group"等其他 NotFound
+const notFoundReason = "KEY_NOT_FOUND"
+
+// isNotFound 判断 err 是否表示键不存在，包括远端节点由 toStatus 返回的 NotFound 状态
+func isNotFound(err error) bool {
+	if errors.Is(err, ErrNotFound) {
+		return true
+	}
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != codes.NotFound {
+		return false
+	}
+	for _, d := range st.Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok && info.GetReason() == notFoundReason {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupNegative 判断 key 是否在负缓存中
+func (g *Group) lookupNegative(key string) bool {
+	if g.negativeTTL <= 0 {
+		return false
+	}
+	v, ok := g.negCache.get(key)
+	ok = ok && g.current(&g.negCache, key, v)
+	if ok {
+		g.stats.negativeHits.Add(1)
+	}
+	return ok
+}
+
+// populateNegative 把 key 在代数 gen 时不存在这个结果加入负缓存
+func (g *Group) populateNegative(key string, gen uint64) {
+	if g.negativeTTL <= 0 {
+		return
+	}
+	g.negCache.add(key, &ByteView{e: time.Now().Add(g.negativeTTL), gen: gen})
+}
+
+// toStatus 把 ErrNotFound 转换为带有 notFoundReason 的 grpc NotFound 状态，其他错误原样返回
+func toStatus(err error) error {
+	if errors.Is(err, ErrNotFound) {
+		st := status.New(codes.NotFound, err.Error())
+		if ds, derr := st.WithDetails(&errdetails.ErrorInfo{Reason: notFoundReason, Domain: "springcache"}); derr == nil {
+			st = ds
+		}
+		return st.Err()
+	}
+	return err
+}
@@ -0,0 +1,239 @@
+package springcache
+
+import (
+	"SpringCache/consistenthash"
+	"SpringCache/logger"
+	"SpringCache/lru"
+	"google.golang.org/grpc"
+	"time"
+)
+
+// GroupOption 用于在 NewGroup 时对 Group 进行配置。
+// 没有设置的项使用 NewGroup 时包级默认值(DefaultExpireTime、lru.DefaultExpireRandom 等)，
+// 之后修改这些默认值不会影响已经创建的 Group
+type GroupOption func(g *Group)
+
+// WithTTL 设置从数据源加载的值的存活时间，ttl <= 0 时等同于 WithNoExpiry
+func WithTTL(ttl time.Duration) GroupOption {
+	return func(g *Group) {
+		g.ttl = ttl
+	}
+}
+
+// WithNoExpiry 让从数据源加载的值永不过期，它们只会因为内存不足被淘汰
+func WithNoExpiry() GroupOption {
+	return func(g *Group) {
+		g.ttl = 0
+	}
+}
+
+// WithExpireJitter 设置过期时间的随机抖动范围，用于防止大量缓存同时过期，0 表示不加抖动
+func WithExpireJitter(jitter time.Duration) GroupOption {
+	return func(g *Group) {
+		g.mainCache.jitter = jitter
+		g.hotCache.jitter = jitter
+	}
+}
+
+// WithHotCacheBytes 设置 hotCache 的最大内存，覆盖 NewGroup 的 hotcacheBytes 参数
+func WithHotCacheBytes(hotcacheBytes int64) GroupOption {
+	return func(g *Group) {
+		g.hotCache.cacheBytes = hotcacheBytes
+	}
+}
+
+// WithMinCacheBytes 设置每个缓存分片的最小内存，默认为 lru.DefaultMaxBytes
+func WithMinCacheBytes(minBytes int64) GroupOption {
+	return func(g *Group) {
+		g.mainCache.minBytes = minBytes
+		g.hotCache.minBytes = minBytes
+	}
+}
+
+// WithEvictionPolicy 设置 mainCache 和 hotCache 的淘汰策略，
+// 例如 WithEvictionPolicy(lru.NewTinyLFU)
+func WithEvictionPolicy(newPolicy lru.NewPolicyFunc) GroupOption {
+	return func(g *Group) {
+		g.mainCache.newPolicy = newPolicy
+		g.hotCache.newPolicy = newPolicy
+	}
+}
+
+// WithMainCachePolicy 只设置 mainCache 的淘汰策略
+func WithMainCachePolicy(newPolicy lru.NewPolicyFunc) GroupOption {
+	return func(g *Group) {
+		g.mainCache.newPolicy = newPolicy
+	}
+}
+
+// WithHotCachePolicy 只设置 hotCache 的淘汰策略
+func WithHotCachePolicy(newPolicy lru.NewPolicyFunc) GroupOption {
+	return func(g *Group) {
+		g.hotCache.newPolicy = newPolicy
+	}
+}
+
+// WithJanitorInterval 设置后台清理过期缓存的间隔，interval <= 0 时不启动后台清理，
+// 过期的数据只会在被访问时惰性删除
+func WithJanitorInterval(interval time.Duration) GroupOption {
+	return func(g *Group) {
+		g.janitorInterval = interval
+	}
+}
+
+// WithHash 设置把键分配到缓存分片时使用的哈希函数，默认为 fnv1
+func WithHash(hash consistenthash.Hash) GroupOption {
+	return func(g *Group) {
+		g.mainCache.hash = hash
+		g.hotCache.hash = hash
+	}
+}
+
+// WithCacheShards 设置 mainCache 和 hotCache 的分片数，
+// 分片越多锁竞争越小，但每个分片分到的内存也越少
+func WithCacheShards(n int) GroupOption {
+	return func(g *Group) {
+		g.mainCache.shardCount = n
+		g.hotCache.shardCount = n
+	}
+}
+
+// WithExpireMode 设置缓存命中时的过期方式：
+// lru.ExpireAbsolute 表示过期时间固定；lru.ExpireSliding 表示每次命中都顺延 idle；
+// lru.ExpireSlidingAbsolute 表示命中时顺延 idle，但不超过设置的过期时间。
+// idle <= 0 时使用键值对自身的存活时间作为空闲超时
+func WithExpireMode(mode lru.ExpireMode, idle time.Duration) GroupOption {
+	return func(g *Group) {
+		g.mainCache.expireMode, g.mainCache.idle = mode, idle
+		g.hotCache.expireMode, g.hotCache.idle = mode, idle
+	}
+}
+
+// WithNegativeCache 设置负缓存的存活时间和最大内存，ttl <= 0 时不缓存不存在的键
+func WithNegativeCache(ttl time.Duration, cacheBytes int64) GroupOption {
+	return func(g *Group) {
+		g.negativeTTL = ttl
+		g.negCache.cacheBytes = cacheBytes
+	}
+}
+
+// WithStaleWhileRevalidate 让过期不超过 grace 的值继续被返回，同时在后台重新加载它，
+// 热点键过期时请求不会阻塞在数据库上
+func WithStaleWhileRevalidate(grace time.Duration) GroupOption {
+	return func(g *Group) {
+		g.grace = grace
+	}
+}
+
+// WithRefreshAhead 让命中时离过期不到 window 的键在后台提前重新加载，
+// 一直被访问的键在过期前就会被刷新
+func WithRefreshAhead(window time.Duration) GroupOption {
+	return func(g *Group) {
+		g.refreshAhead = window
+	}
+}
+
+// WithStaleIfError 让过期的值继续保留 d，数据源或远端节点出错时返回这些旧值而不是错误，
+// 返回的值的 Stale 为 true
+func WithStaleIfError(d time.Duration) GroupOption {
+	return func(g *Group) {
+		g.staleIfError = d
+	}
+}
+
+// WithHotKeys 开启热点键识别：从远端节点加载次数达到 threshold 的键会被放入本地的 hotCache，
+// 最长存活 ttl(加上 hotCache 的过期抖动)。计数会随时间衰减，只有近期频繁访问的键才是热点
+func WithHotKeys(threshold int, ttl time.Duration) GroupOption {
+	return func(g *Group) {
+		g.hotKeys = newHotKeys(threshold, ttl)
+	}
+}
+
+// WithCompression 开启值压缩，长度不小于 threshold 的值用 c 压缩后保存，c 为空时使用 FlateCompressor。
+// threshold < 0 时使用 DefaultCompressThreshold
+func WithCompression(c Compressor, threshold int) GroupOption {
+	return func(g *Group) {
+		if c == nil {
+			c = FlateCompressor{}
+		}
+		if threshold < 0 {
+			threshold = DefaultCompressThreshold
+		}
+		g.compressor = c
+		g.compressThreshold = threshold
+	}
+}
+
+// WithLogger 设置 Group 的日志，默认不输出日志
+func WithLogger(l logger.Logger) GroupOption {
+	return func(g *Group) {
+		g.logger = logger.OrDiscard(l)
+	}
+}
+
+// WithWriteThrough 让 Set 先用 setter 写数据源，成功后再修改缓存。
+// deleter 不为空时 Remove 也会先从数据源中删除这个键
+func WithWriteThrough(setter Setter, deleter Deleter) GroupOption {
+	if setter == nil {
+		panic("springcache: setter is nil")
+	}
+	return func(g *Group) {
+		g.writeMode = WriteThrough
+		g.setter = setter
+		g.deleter = deleter
+	}
+}
+
+// WithWriteBehind 让 Set 和 Remove 先修改缓存，再由后台协程每隔 interval 批量写回数据源，
+// 同一个键的多次写只写最后一次，失败的写最多重试 retries 次。interval <= 0 时使用 DefaultWriteBehindInterval
+func WithWriteBehind(setter Setter, deleter Deleter, interval time.Duration, retries int) GroupOption {
+	if setter == nil {
+		panic("springcache: setter is nil")
+	}
+	return func(g *Group) {
+		g.writeMode = WriteBehind
+		g.setter = setter
+		g.deleter = deleter
+		g.writer = newWriteBehind(setter, deleter, interval, retries)
+	}
+}
+
+// WithMiddleware 注册包装数据源的中间件，先注册的在外层，可以多次调用
+func WithMiddleware(mws ...Middleware) GroupOption {
+	return func(g *Group) {
+		g.middlewares = append(g.middlewares, mws...)
+	}
+}
+
+// ServerOption 用于在 NewServer 时对 Server 进行配置
+type ServerOption func(s *Server)
+
+// WithServerLogger 设置 Server 以及它创建的 connect.Client 的日志，默认不输出日志
+func WithServerLogger(l logger.Logger) ServerOption {
+	return func(s *Server) {
+		s.logger = logger.OrDiscard(l)
+	}
+}
+
+// WithSnapshot 让 Server 在 StartServer 时从 path 读取快照预热所有已经创建的 Group，
+// 在 Stop 时以及每隔 interval 把所有 Group 的缓存保存到 path，interval <= 0 时不定期保存
+func WithSnapshot(path string, interval time.Duration) ServerOption {
+	return func(s *Server) {
+		s.snapshotPath = path
+		s.snapshotInterval = interval
+	}
+}
+
+// WithUnaryInterceptors 设置 StartServer 启动的 grpc 服务端的一元拦截器，先传入的在外层
+func WithUnaryInterceptors(interceptors ...grpc.UnaryServerInterceptor) ServerOption {
+	return func(s *Server) {
+		s.serverInterceptors = append(s.serverInterceptors, interceptors...)
+	}
+}
+
+// WithClientInterceptors 设置 Server 访问其他节点时使用的 grpc 客户端一元拦截器，先传入的在外层
+func WithClientInterceptors(interceptors ...grpc.UnaryClientInterceptor) ServerOption {
+	return func(s *Server) {
+		s.clientInterceptors = append(s.clientInterceptors, interceptors...)
+	}
+}
@@ -0,0 +1,81 @@
+package springcache
+
+import (
+	"context"
+	"time"
+)
+
+// 后台刷新：stale-while-revalidate 在值过期后的宽限期内先返回旧值，
+// refresh-ahead 在被访问的值快要过期时提前加载，两者都由同一个后台刷新完成，
+// 每个键同一时间只有一个刷新在进行。
+// stale-if-error 在数据源或远端节点出错时返回还在保留期内的旧值
+
+// lookupStale 返回 mainCache 中过期不超过宽限期的旧值，并在后台刷新它
+func (g *Group) lookupStale(key string) (*ByteView, bool) {
+	if g.grace <= 0 {
+		return nil, false
+	}
+	v, stale, ok := g.mainCache.getStale(key)
+	if !ok || !g.current(&g.mainCache, key, v) {
+		return nil, false
+	}
+	if stale && time.Since(v.Expire()) > g.grace {
+		return nil, false
+	}
+	g.stats.mainCacheHits.Add(1)
+	g.refresh(key)
+	return v, true
+}
+
+// maybeRefreshAhead 在命中的值离过期不到 refreshAhead 时在后台刷新它
+func (g *Group) maybeRefreshAhead(key string, v *ByteView) {
+	if g.refreshAhead <= 0 || v.Expire().IsZero() {
+		return
+	}
+	if time.Until(v.Expire()) < g.refreshAhead {
+		g.refresh(key)
+	}
+}
+
+// refresh 在后台重新加载 key，key 已经在刷新时直接返回
+func (g *Group) refresh(key string) {
+	g.refreshMu.Lock()
+	if _, ok := g.refreshing[key]; ok {
+		g.refreshMu.Unlock()
+		return
+	}
+	g.refreshing[key] = struct{}{}
+	g.refreshMu.Unlock()
+
+	go func() {
+		defer func() {
+			g.refreshMu.Lock()
+			delete(g.refreshing, key)
+			g.refreshMu.Unlock()
+		}()
+		if _, err := g.LoadContext(context.Background(), key); err != nil {
+			g.logger.Warn("refresh failed", "group", g.name, "key", key, "err", err)
+		}
+	}()
+}
+
+// staleOnError 在加载 key 出错时返回过期不超过 staleIfError 的旧值，返回的值被标记为 stale。
+// 键不存在或者调用方已经放弃时不返回旧值
+func (g *Group) staleOnError(ctx context.Context, key string, err error) (*ByteView, bool) {
+	if g.staleIfError <= 0 || isNotFound(err) || ctx.Err() != nil {
+		return nil, false
+	}
+	v, _, ok := g.mainCache.getStale(key)
+	ok = ok && g.current(&g.mainCache, key, v)
+	if !ok {
+		v, _, ok = g.hotCache.getStale(key)
+		ok = ok && g.current(&g.hotCache, key, v)
+	}
+	if !ok || time.Since(v.Expire()) > g.staleIfError {
+		return nil, false
+	}
+	g.logger.Warn("serve stale value after load error", "group", g.name, "key", key, "err", err)
+	sv := *v
+	sv.stale = true
+	return &sv, true
+}
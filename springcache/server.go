@@ -3,12 +3,14 @@ package springcache
 import (
 	"SpringCache/connect"
 	"SpringCache/consistenthash"
+	"SpringCache/logger"
 	pb "SpringCache/springcachepb"
 	"fmt"
 	"github.com/pkg/errors"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
-	"log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"net"
 	"strings"
 	"sync"
@@ -21,6 +23,7 @@ var (
 	ErrorTcpListen        = errors.New("tcp listen error")
 	ErrorRegisterEtcd     = errors.New("register etcd error")
 	ErrorGrpcServerStart  = errors.New("start grpc server error")
+	ErrorNoEtcd           = errors.New("server has no etcd client")
 )
 
 var (
@@ -41,53 +44,165 @@ type Server struct {
 	etcd    *connect.Etcd
 	name    string
 	clients map[string]*connect.Client // 【节点名】客户端
+	logger  logger.Logger
+
+	serverInterceptors []grpc.UnaryServerInterceptor // grpc 服务端的一元拦截器
+	clientInterceptors []grpc.UnaryClientInterceptor // 访问其他节点时的一元拦截器
+
+	grpcServer       *grpc.Server
+	stop             chan struct{} // Stop 时关闭，通知后台协程退出
+	snapshotPath     string        // 快照文件，为空时不保存快照
+	snapshotInterval time.Duration // 定期保存快照的间隔，<= 0 时只在 Stop 时保存
 }
 
 // NewServer 会创建一个grpc服务端，并绑定与etcd进行绑定
-func NewServer(serverName, selfAddr string, etcd *connect.Etcd) *Server {
+func NewServer(serverName, selfAddr string, etcd *connect.Etcd, opts ...ServerOption) *Server {
 
-	return &Server{
+	s := &Server{
 		self:    selfAddr,
 		status:  false,
 		peers:   consistenthash.New(defaultReplicas, nil),
 		etcd:    etcd,
 		clients: make(map[string]*connect.Client),
 		name:    serverName,
+		logger:  logger.Discard,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// lookupGroup 返回名为 name 的 Group，不存在时返回 grpc 的 NotFound 状态。
+// 这个状态不带 notFoundReason，调用方不会把它当作"键不存在"
+func lookupGroup(name string) (*Group, error) {
+	group := GetGroup(name)
+	if group == nil {
+		return nil, status.Errorf(codes.NotFound, "springcache: group %s not found", name)
 	}
+	return group, nil
 }
 
 // 实现grpc定义的接口Get，即当远端调用该节点时查找缓存时，返回对应的值
 func (s *Server) Get(ctx context.Context, in *pb.GetRequest) (out *pb.GetResponse, err error) {
 	groupName, key := in.GetGroup(), in.GetKey()
-	group := GetGroup(groupName)
-	bytes, err := group.Get(key)
+	group, err := lookupGroup(groupName)
 	if err != nil {
 		return nil, err
 	}
+	bytes, err := group.GetContext(ctx, key)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	// 压缩过的值原样发送，由调用方解压
 	out = &pb.GetResponse{
-		Value: bytes.ByteSlice(),
+		Value:      bytes.b,
+		Expire:     expireToUnix(bytes.Expire()),
+		Stale:      bytes.Stale(),
+		Compressed: bytes.Compressed(),
+		Tags:       bytes.tags,
 	}
 	return out, nil
 }
 
 // 实现grpc定义的接口Set，远端调用该节点设置缓存
 func (s *Server) Set(ctx context.Context, in *pb.SetRequest) (out *pb.SetResponse, err error) {
-	groupName, key, value, expire := in.GetGroup(), in.GetKey(), in.GetValue(), in.GetExpire()
-	ishot := in.GetIshot()
-	group := GetGroup(groupName)
-	bytes := NewByteView(value, time.Unix(expire, 0))
+	groupName, key, ishot := in.GetGroup(), in.GetKey(), in.GetIshot()
+	group, err := lookupGroup(groupName)
+	if err != nil {
+		return nil, err
+	}
+	bytes, err := group.peerView(in)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
 	out = &pb.SetResponse{
 		Ok: false,
 	}
-	err = group.Set(key, bytes, ishot)
+	// 数据源已经由发起请求的节点写过，这里只设置缓存
+	if ishot {
+		err = group.setHotCache(key, bytes)
+	} else {
+		err = group.setCache(ctx, key, bytes)
+	}
 	if err != nil {
 		return out, err
 	}
 	return &pb.SetResponse{Ok: true}, nil
 }
 
+// 实现grpc定义的接口Delete，远端调用该节点删除缓存。
+// 这里只删除本地缓存，不再转发，避免节点间哈希环不一致时请求来回转发
+func (s *Server) Delete(ctx context.Context, in *pb.DeleteRequest) (out *pb.DeleteResponse, err error) {
+	groupName, key := in.GetGroup(), in.GetKey()
+	group, err := lookupGroup(groupName)
+	if err != nil {
+		return nil, err
+	}
+	group.removeLocally(key)
+	return &pb.DeleteResponse{Ok: true}, nil
+}
+
+// 实现grpc定义的接口InvalidateTag，只删除本节点中带有标签的键，不再通知其他节点
+func (s *Server) InvalidateTag(ctx context.Context, in *pb.InvalidateTagRequest) (out *pb.InvalidateTagResponse, err error) {
+	group, err := lookupGroup(in.GetGroup())
+	if err != nil {
+		return nil, err
+	}
+	removed := group.removeTagLocally(in.GetTag())
+	return &pb.InvalidateTagResponse{Removed: int64(removed)}, nil
+}
+
+// 实现grpc定义的接口GetMulti，在一次调用中返回多个键的值，每个键的错误分别返回。
+// 与 Delete 一样只在本节点处理，不再转发给其他节点
+func (s *Server) GetMulti(ctx context.Context, in *pb.GetMultiRequest) (out *pb.GetMultiResponse, err error) {
+	groupName, keys := in.GetGroup(), in.GetKeys()
+	group, err := lookupGroup(groupName)
+	if err != nil {
+		return nil, err
+	}
+	values, errs := group.getMulti(ctx, keys, false)
+	out = &pb.GetMultiResponse{Items: make([]*pb.MultiItem, 0, len(keys))}
+	for _, key := range keys {
+		item := &pb.MultiItem{Key: key}
+		if err, ok := errs[key]; ok {
+			item.Error = err.Error()
+			item.NotFound = isNotFound(err)
+		} else if v, ok := values[key]; ok {
+			item = viewToMultiItem(key, v)
+		}
+		out.Items = append(out.Items, item)
+	}
+	return out, nil
+}
+
+// 实现grpc定义的接口SetMulti，在一次调用中设置多个键，只返回设置失败的键。
+// 只设置本节点的缓存，不再转发给其他节点
+func (s *Server) SetMulti(ctx context.Context, in *pb.SetMultiRequest) (out *pb.SetMultiResponse, err error) {
+	groupName, items, ishot := in.GetGroup(), in.GetItems(), in.GetIshot()
+	group, err := lookupGroup(groupName)
+	if err != nil {
+		return nil, err
+	}
+	values, errs := group.multiItemsToViews(items)
+	for key, err := range group.setMultiLocally(values, ishot) {
+		errs[key] = err
+	}
+	out = &pb.SetMultiResponse{}
+	for key, err := range errs {
+		out.Items = append(out.Items, &pb.MultiItem{Key: key, Error: err.Error()})
+	}
+	return out, nil
+}
+
+// Log 以 Debug 级别输出一条格式化的日志
 func (s *Server) Log(format string, v ...interface{}) {
-	log.Printf("[Server %s] %s", s.self, fmt.Sprintf(format, v...))
+	s.log().Debug(fmt.Sprintf(format, v...), "server", s.self)
+}
+
+// log 返回 Server 的日志，直接构造的 Server 没有设置日志时不输出
+func (s *Server) log() logger.Logger {
+	return logger.OrDiscard(s.logger)
 }
 
 // SetPeers 会把节点名在etcd中进行服务发现，并把获取的ip地址加入到哈希环中，并且把客户端保存到clients这个map中方便后面调用
@@ -97,13 +212,13 @@ func (s *Server) SetPeers(names ...string) {
 		//log.Printf("debug, In server.SetPeers, name:", name)
 		ip, err := connect.GetAddrByName(s.etcd.EtcdCli, name)
 		if err != nil {
-			log.Printf("SetPeers err : %v", err)
+			s.log().Error("set peers failed", "server", s.self, "peer", name, "err", err)
 			return
 		}
 		//log.Printf("debug, In server.SetPeers, ip:", ip)
 		addr := strings.Split(ip, ":")[0]
 		s.peers.AddNodes(addr)
-		s.clients[addr] = &connect.Client{name, s.etcd}
+		s.clients[addr] = &connect.Client{Name: name, Etcd: s.etcd, Logger: s.logger, Interceptors: s.clientInterceptors}
 	}
 	//log.Println("SetPeers success, s.clients =", s.clients)
 }
@@ -116,7 +231,7 @@ func (s *Server) PickPeer(key string) (connect.PeerGetter, bool) {
 	if peer := s.peers.Get(key); peer != "" {
 		ip := strings.Split(s.self, ":")[0]
 		if peer == ip {
-			log.Println("ops! peek my self! , i am :", peer)
+			s.log().Debug("pick self", "server", s.self, "peer", peer)
 			return nil, false
 		}
 		s.Log("Pick peer %s", peer)
@@ -125,11 +240,23 @@ func (s *Server) PickPeer(key string) (connect.PeerGetter, bool) {
 	return nil, false
 }
 
+// Peers 返回除自己以外的所有节点的客户端
+func (s *Server) Peers() []connect.PeerGetter {
+	ip := strings.Split(s.self, ":")[0]
+	peers := make([]connect.PeerGetter, 0, len(s.clients))
+	for addr, client := range s.clients {
+		if addr != ip {
+			peers = append(peers, client)
+		}
+	}
+	return peers
+}
+
 // 根据key找出移除哈希环上存储该键值对的节点，并移除这个节点
 func (s *Server) RemovePeerByKey(key string) {
 	peer := s.peers.Get(key)
 	s.peers.Remove(peer)
-	log.Printf("RemovePeer %s", peer)
+	s.log().Info("remove peer", "server", s.self, "peer", peer)
 }
 
 // StartServer 开启grpc服务，并在etcd上注册
@@ -147,21 +274,103 @@ func (s *Server) StartServer() error {
 	// 开启grpc
 	lis, err := net.Listen("tcp", defaultListenAddr)
 	if err != nil {
-		log.Println("listen server error:", err)
+		s.mu.Unlock()
+		s.log().Error("listen failed", "server", s.self, "err", err)
 		return ErrorTcpListen
 	}
-	grpcServer := grpc.NewServer()
+	// 在开始处理请求之前从快照中预热缓存
+	if s.snapshotPath != "" {
+		if err := s.LoadSnapshot(); err != nil {
+			s.log().Error("load snapshot failed", "server", s.self, "path", s.snapshotPath, "err", err)
+		}
+	}
+	grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(s.serverInterceptors...))
 	pb.RegisterSpringCacheServer(grpcServer, s)
+	s.grpcServer = grpcServer
+	s.stop = make(chan struct{})
+	s.status = true
+	s.mu.Unlock()
+	if s.etcd != nil {
+		go s.watchGenerations(s.stop)
+	}
+	if s.snapshotPath != "" && s.snapshotInterval > 0 {
+		go s.snapshotLoop(s.stop)
+	}
 
-	log.Println("start grpc server:", s.self)
+	s.log().Info("start grpc server", "server", s.self)
 	err = grpcServer.Serve(lis)
 	if err != nil {
-		log.Println(ErrorGrpcServerStart, "err： ", err)
+		s.log().Error("grpc server stopped", "server", s.self, "err", err)
 		return ErrorGrpcServerStart
 	}
-	s.status = true
-	s.mu.Unlock()
 	return nil
 }
 
+// Stop 优雅地停止 grpc 服务，等待正在处理的请求结束后返回。
+// 设置了快照文件时，停止后再保存一次快照
+func (s *Server) Stop() {
+	s.mu.Lock()
+	if !s.status {
+		s.mu.Unlock()
+		return
+	}
+	s.status = false
+	grpcServer, stop := s.grpcServer, s.stop
+	s.mu.Unlock()
+	close(stop)
+	grpcServer.GracefulStop()
+	if s.snapshotPath != "" {
+		if err := s.SaveSnapshot(); err != nil {
+			s.log().Error("save snapshot failed", "server", s.self, "path", s.snapshotPath, "err", err)
+		}
+	}
+}
+
+// BumpGeneration 在 etcd 中把 group 的代数加一并返回新的代数，
+// 所有节点监听到变化后，这个 Group 中旧的缓存都会失效
+func (s *Server) BumpGeneration(ctx context.Context, group string) (uint64, error) {
+	if s.etcd == nil {
+		return 0, ErrorNoEtcd
+	}
+	gen, err := s.etcd.BumpGeneration(ctx, group)
+	if err != nil {
+		return 0, err
+	}
+	if g := GetGroup(group); g != nil {
+		g.SetGeneration(gen)
+	}
+	return gen, nil
+}
+
+// watchGenerations 监听 etcd 中每个 Group 的代数并更新本地的 Group，出错时等待一段时间后重新监听，
+// stop 被关闭后退出
+func (s *Server) watchGenerations(stop <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	for {
+		err := s.etcd.WatchGenerations(ctx, func(group string, gen uint64) {
+			if g := GetGroup(group); g != nil {
+				g.SetGeneration(gen)
+			}
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		s.log().Warn("watch generations stopped, retrying", "server", s.self, "err", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
 var _ connect.PeerPicker = (*Server)(nil)
+var _ connect.PeerLister = (*Server)(nil)
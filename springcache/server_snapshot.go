@@ -0,0 +1,128 @@
+package springcache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Server 的快照文件格式与 Group.Snapshot 相同的文件头，之后每个 Group 依次是
+// 一个 1 字节、Group 的名字和它的记录，以一个 0 字节结束。
+// 读回时跳过本节点上不存在的 Group
+
+// SaveSnapshot 把所有 Group 的缓存保存到快照文件。先写入同目录下的临时文件再重命名，
+// 保存失败不会破坏之前的快照
+func (s *Server) SaveSnapshot() error {
+	f, err := os.CreateTemp(filepath.Dir(s.snapshotPath), filepath.Base(s.snapshotPath)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := writeGroupsSnapshot(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), s.snapshotPath)
+}
+
+// LoadSnapshot 从快照文件中读回所有 Group 的缓存，文件不存在时什么也不做。
+// 有 etcd 时先从 etcd 读取每个 Group 当前的代数，停机期间代数增加过的 Group 会丢弃快照中的值
+func (s *Server) LoadSnapshot() error {
+	if s.etcd != nil {
+		if err := s.syncGenerations(); err != nil {
+			return err
+		}
+	}
+	f, err := os.Open(s.snapshotPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return readGroupsSnapshot(f)
+}
+
+// syncGenerations 把每个已经创建的 Group 的代数更新为 etcd 中的代数
+func (s *Server) syncGenerations() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	for _, name := range ListGroups() {
+		gen, err := s.etcd.Generation(ctx, name)
+		if err != nil {
+			return err
+		}
+		if g := GetGroup(name); g != nil {
+			g.SetGeneration(gen)
+		}
+	}
+	return nil
+}
+
+// snapshotLoop 每隔 snapshotInterval 保存一次快照，直到 stop 被关闭
+func (s *Server) snapshotLoop(stop <-chan struct{}) {
+	ticker := time.NewTicker(s.snapshotInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := s.SaveSnapshot(); err != nil {
+				s.log().Error("save snapshot failed", "server", s.self, "path", s.snapshotPath, "err", err)
+			}
+		}
+	}
+}
+
+// writeGroupsSnapshot 把所有已经注册的 Group 写入 w
+func writeGroupsSnapshot(w io.Writer) error {
+	sw := newSnapshotWriter(w)
+	sw.header()
+	for _, name := range ListGroups() {
+		g := GetGroup(name)
+		if g == nil {
+			continue
+		}
+		sw.byte(1)
+		sw.bytes([]byte(name))
+		g.writeEntries(sw)
+	}
+	sw.byte(0)
+	return sw.flush()
+}
+
+// readGroupsSnapshot 从 r 中读回 writeGroupsSnapshot 写入的 Group
+func readGroupsSnapshot(r io.Reader) error {
+	sr := newSnapshotReader(r)
+	if err := sr.header(); err != nil {
+		return err
+	}
+	for {
+		more, err := sr.byte()
+		if err != nil {
+			return err
+		}
+		if more == 0 {
+			return nil
+		}
+		name, err := sr.bytes()
+		if err != nil {
+			return err
+		}
+		if err := GetGroup(string(name)).readEntries(sr); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package springcache
+
+import (
+	"SpringCache/lru"
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// 快照：把 mainCache 和 hotCache 中的键值对写成二进制格式，节点重启后读回来预热缓存。
+// 格式为 魔数 "SPCS"、一个字节的版本号、写快照时 Group 的代数(uvarint)，之后是若干条记录，以一个 0 字节结束。
+// 每条记录依次是 缓存类型(1 字节)、键、值、过期时间(unix 纳秒，0 表示永不过期)和标签，
+// 键、值和标签都以 uvarint 长度作为前缀。值保存解压后的字节，读回时按当前配置重新压缩
+
+const (
+	snapshotMagic   = "SPCS"
+	snapshotVersion = 2
+	// maxSnapshotField 是快照中单个键、值或标签的最大长度，用于识别损坏的快照
+	maxSnapshotField = 1 << 30
+)
+
+var (
+	// ErrSnapshotFormat 表示读到的数据不是快照或者已经损坏
+	ErrSnapshotFormat = errors.New("springcache: invalid snapshot")
+	// ErrSnapshotVersion 表示快照的版本不被当前代码支持
+	ErrSnapshotVersion = errors.New("springcache: unsupported snapshot version")
+)
+
+// Snapshot 把 mainCache 和 hotCache 中没有过期的键值对写入 w，写入时不会阻塞对缓存的读写
+func (g *Group) Snapshot(w io.Writer) error {
+	sw := newSnapshotWriter(w)
+	sw.header()
+	g.writeEntries(sw)
+	return sw.flush()
+}
+
+// Restore 从 r 中读取 Snapshot 写入的键值对并加入对应的缓存，已经过期的键值对会被跳过。
+// 读回的值使用快照中的过期时间，不会再加随机抖动。
+// 只有快照的代数与 Group 当前的代数相同时才读回键值对，否则所有的键值对都会被丢弃。
+// Restore 不会改变 Group 的代数，代数由 SetGeneration 或 etcd 决定
+func (g *Group) Restore(r io.Reader) error {
+	sr := newSnapshotReader(r)
+	if err := sr.header(); err != nil {
+		return err
+	}
+	return g.readEntries(sr)
+}
+
+// writeEntries 写入 Group 的代数、所有记录和结束标记
+func (g *Group) writeEntries(sw *snapshotWriter) {
+	gen := g.Generation()
+	sw.uvarint(gen)
+	for _, kind := range []CacheType{MainCache, HotCache} {
+		// 先在分片的锁内收集键值对，写入 w 时不持有锁
+		var items []lru.Item
+		g.cache(kind).rangeItems(func(item lru.Item) bool {
+			if item.Value.(*ByteView).gen == gen {
+				items = append(items, item)
+			}
+			return true
+		})
+		for _, item := range items {
+			v := item.Value.(*ByteView)
+			b := v.data()
+			if b == nil && v.Len() > 0 {
+				g.logger.Warn("skip undecodable value in snapshot", "group", g.name, "key", item.Key)
+				continue
+			}
+			sw.byte(byte(kind))
+			sw.bytes([]byte(item.Key))
+			sw.bytes(b)
+			sw.int64(expireToNano(item.Expire))
+			sw.uvarint(uint64(len(v.tags)))
+			for _, tag := range v.tags {
+				sw.bytes([]byte(tag))
+			}
+		}
+	}
+	sw.byte(0)
+}
+
+// readEntries 读取代数和记录直到结束标记，g 为 nil 或快照的代数与当前的代数不同时只跳过这些记录
+func (g *Group) readEntries(sr *snapshotReader) error {
+	gen, err := sr.uvarint()
+	if err != nil {
+		return err
+	}
+	if g != nil && g.Generation() != gen {
+		g.logger.Info("drop snapshot of another generation", "group", g.name, "snapshot_generation", gen, "generation", g.Generation())
+		g = nil
+	}
+	now := time.Now()
+	restored := 0
+	for {
+		kind, err := sr.byte()
+		if err != nil {
+			return err
+		}
+		if kind == 0 {
+			break
+		}
+		if CacheType(kind) != MainCache && CacheType(kind) != HotCache {
+			return fmt.Errorf("%w: unknown cache type %d", ErrSnapshotFormat, kind)
+		}
+		key, err := sr.bytes()
+		if err != nil {
+			return err
+		}
+		value, err := sr.bytes()
+		if err != nil {
+			return err
+		}
+		nano, err := sr.int64()
+		if err != nil {
+			return err
+		}
+		n, err := sr.uvarint()
+		if err != nil {
+			return err
+		}
+		var tags []string
+		for i := uint64(0); i < n; i++ {
+			tag, err := sr.bytes()
+			if err != nil {
+				return err
+			}
+			tags = append(tags, string(tag))
+		}
+		expire := nanoToExpire(nano)
+		if g == nil || (!expire.IsZero() && !expire.After(now)) {
+			continue
+		}
+		v := g.stamp(g.compress(&ByteView{b: value, e: expire, tags: tags}), g.Generation())
+		if CacheType(kind) == MainCache {
+			g.negCache.remove(string(key))
+		}
+		g.cache(CacheType(kind)).addExact(string(key), v)
+		restored++
+	}
+	if g != nil {
+		g.logger.Info("restore snapshot", "group", g.name, "restored", restored)
+	}
+	return nil
+}
+
+func expireToNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func nanoToExpire(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
+// snapshotWriter 按快照格式写入数据，遇到的第一个错误在 flush 时返回
+type snapshotWriter struct {
+	w   *bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+	err error
+}
+
+func newSnapshotWriter(w io.Writer) *snapshotWriter {
+	return &snapshotWriter{w: bufio.NewWriter(w)}
+}
+
+func (sw *snapshotWriter) header() {
+	sw.write([]byte(snapshotMagic))
+	sw.byte(snapshotVersion)
+}
+
+func (sw *snapshotWriter) write(b []byte) {
+	if sw.err == nil {
+		_, sw.err = sw.w.Write(b)
+	}
+}
+
+func (sw *snapshotWriter) byte(b byte) {
+	if sw.err == nil {
+		sw.err = sw.w.WriteByte(b)
+	}
+}
+
+func (sw *snapshotWriter) uvarint(x uint64) {
+	sw.write(sw.buf[:binary.PutUvarint(sw.buf[:], x)])
+}
+
+func (sw *snapshotWriter) int64(x int64) {
+	binary.BigEndian.PutUint64(sw.buf[:8], uint64(x))
+	sw.write(sw.buf[:8])
+}
+
+func (sw *snapshotWriter) bytes(b []byte) {
+	sw.uvarint(uint64(len(b)))
+	sw.write(b)
+}
+
+func (sw *snapshotWriter) flush() error {
+	if sw.err != nil {
+		return sw.err
+	}
+	return sw.w.Flush()
+}
+
+// snapshotReader 按快照格式读取数据，数据提前结束时返回 ErrSnapshotFormat
+type snapshotReader struct {
+	r *bufio.Reader
+}
+
+func newSnapshotReader(r io.Reader) *snapshotReader {
+	return &snapshotReader{r: bufio.NewReader(r)}
+}
+
+func (sr *snapshotReader) header() error {
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(sr.r, magic); err != nil || string(magic) != snapshotMagic {
+		return ErrSnapshotFormat
+	}
+	version, err := sr.byte()
+	if err != nil {
+		return err
+	}
+	if version != snapshotVersion {
+		return fmt.Errorf("%w: %d", ErrSnapshotVersion, version)
+	}
+	return nil
+}
+
+func (sr *snapshotReader) byte() (byte, error) {
+	b, err := sr.r.ReadByte()
+	return b, sr.check(err)
+}
+
+func (sr *snapshotReader) uvarint() (uint64, error) {
+	x, err := binary.ReadUvarint(sr.r)
+	return x, sr.check(err)
+}
+
+func (sr *snapshotReader) int64() (int64, error) {
+	var buf [8]byte
+	if _, err := io.ReadFull(sr.r, buf[:]); err != nil {
+		return 0, sr.check(err)
+	}
+	return int64(binary.BigEndian.Uint64(buf[:])), nil
+}
+
+func (sr *snapshotReader) bytes() ([]byte, error) {
+	n, err := sr.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if n > maxSnapshotField {
+		return nil, fmt.Errorf("%w: field of %d bytes", ErrSnapshotFormat, n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(sr.r, b); err != nil {
+		return nil, sr.check(err)
+	}
+	return b, nil
+}
+
+// check 把数据提前结束的错误转换为 ErrSnapshotFormat
+func (sr *snapshotReader) check(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: unexpected end of data", ErrSnapshotFormat)
+	}
+	return err
+}
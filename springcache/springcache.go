@@ -1,5 +1,10 @@
 package springcache
 
+import (
+	"context"
+	"time"
+)
+
 // A Getter loads data for a key.
 // 设计一个回调函数,缓存未命中时会调用这个函数,去获取源数据
 type Getter interface {
@@ -14,3 +19,87 @@ type GetterFunc func(key string) ([]byte, error)
 func (f GetterFunc) Get(key string) ([]byte, error) {
 	return f(key)
 }
+
+// A ContextGetter loads data for a key with a context.
+// 与 Getter 相同，但会收到调用者的 ctx，数据源可以据此感知超时和取消
+type ContextGetter interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+// ContextGetterFunc implements ContextGetter
+type ContextGetterFunc func(ctx context.Context, key string) ([]byte, error)
+
+func (f ContextGetterFunc) Get(ctx context.Context, key string) ([]byte, error) {
+	return f(ctx, key)
+}
+
+// getterAdapter 把不感知 ctx 的 Getter 包装成 ContextGetter
+type getterAdapter struct {
+	getter Getter
+}
+
+func (a getterAdapter) Get(_ context.Context, key string) ([]byte, error) {
+	return a.getter.Get(key)
+}
+
+// Result 是数据源返回的加载结果，除了值之外还可以决定这个值如何被缓存
+type Result struct {
+	Value []byte
+	// Expire 不为零时作为值的过期时间
+	Expire time.Time
+	// TTL 大于 0 且没有设置 Expire 时，值在 TTL 后过期。两者都没有设置时使用 Group 的 TTL
+	TTL time.Duration
+	// NoCache 为 true 时值会返回给调用者，但不会被缓存
+	NoCache bool
+	// Tags 是值的标签，可以用 Group.InvalidateTag 删除带有某个标签的所有键
+	Tags []string
+}
+
+// A ResultGetter loads data for a key together with how it should be cached.
+// 扩展的 Getter，数据源可以为每个键单独指定过期时间，或者要求不缓存
+type ResultGetter interface {
+	GetResult(ctx context.Context, key string) (Result, error)
+}
+
+// ResultGetterFunc implements ResultGetter
+type ResultGetterFunc func(ctx context.Context, key string) (Result, error)
+
+func (f ResultGetterFunc) GetResult(ctx context.Context, key string) (Result, error) {
+	return f(ctx, key)
+}
+
+// contextGetterAdapter 把 ContextGetter 包装成 ResultGetter，使用 Group 的默认过期时间
+type contextGetterAdapter struct {
+	getter ContextGetter
+}
+
+func (a contextGetterAdapter) GetResult(ctx context.Context, key string) (Result, error) {
+	value, err := a.getter.Get(ctx, key)
+	return Result{Value: value}, err
+}
+
+// A Setter writes data for a key to the backing store.
+// 与 Getter 对应，Group.Set 时把值写回数据源
+type Setter interface {
+	Set(ctx context.Context, key string, value []byte) error
+}
+
+// SetterFunc implements Setter
+type SetterFunc func(ctx context.Context, key string, value []byte) error
+
+func (f SetterFunc) Set(ctx context.Context, key string, value []byte) error {
+	return f(ctx, key, value)
+}
+
+// A Deleter deletes a key from the backing store.
+// Group.Remove 时从数据源中删除这个键
+type Deleter interface {
+	Delete(ctx context.Context, key string) error
+}
+
+// DeleterFunc implements Deleter
+type DeleterFunc func(ctx context.Context, key string) error
+
+func (f DeleterFunc) Delete(ctx context.Context, key string) error {
+	return f(ctx, key)
+}
@@ -1,8 +1,23 @@
 package springcache
 
 import (
+	"SpringCache/connect"
+	"SpringCache/logger"
+	"SpringCache/lru"
+	pb "SpringCache/springcachepb"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"path/filepath"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestGetter(t *testing.T) {
@@ -15,3 +30,1193 @@ func TestGetter(t *testing.T) {
 		t.Errorf("callback failed")
 	}
 }
+
+func TestJanitor(t *testing.T) {
+	g := mustGroup(t)(NewGroup("janitor", 2<<10, 2<<7, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}), WithJanitorInterval(10*time.Millisecond)))
+	defer g.Close()
+
+	g.mainCache.add("expired", NewByteView([]byte("v"), time.Now().Add(-time.Hour)))
+	g.hotCache.add("expired", NewByteView([]byte("v"), time.Now().Add(-time.Hour)))
+	deadline := time.Now().Add(time.Second)
+	for g.mainCache.len() > 0 || g.hotCache.len() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("janitor did not remove expired entries")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestCacheShards(t *testing.T) {
+	c := &cache{cacheBytes: 4 * minShardBytes, shardCount: 4}
+	expire := time.Now().Add(time.Hour)
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%d", i)
+		c.add(key, NewByteView([]byte(key), expire))
+	}
+	if len(c.shards) != 4 {
+		t.Fatalf("expected 4 shards, got %d", len(c.shards))
+	}
+	if n := c.len(); n != 100 {
+		t.Fatalf("expected 100 entries, got %d", n)
+	}
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%d", i)
+		if v, ok := c.get(key); !ok || v.String() != key {
+			t.Fatalf("cache get %s failed", key)
+		}
+	}
+
+	small := &cache{cacheBytes: 2 << 10, shardCount: 16}
+	small.add("key", NewByteView([]byte("value"), expire))
+	if len(small.shards) != 1 {
+		t.Fatalf("expected a small cache to use 1 shard, got %d", len(small.shards))
+	}
+}
+
+func TestGroupInspect(t *testing.T) {
+	g := mustGroup(t)(NewGroup("inspect", 2<<10, 2<<7, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	})))
+	defer g.Close()
+
+	for _, key := range []string{"a", "b", "c"} {
+		if _, err := g.Get(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g.hotCache.add("hot", NewByteView([]byte("h"), time.Now().Add(time.Hour)))
+
+	if v, ok := g.Peek("b"); !ok || v.String() != "b" {
+		t.Fatalf("peek b failed")
+	}
+	if !g.Contains("hot") || g.Contains("missing") {
+		t.Fatalf("contains reported wrong result")
+	}
+	if key, _, ok := g.Oldest(MainCache); !ok || key != "a" {
+		t.Fatalf("expected oldest key to be a, got %q", key)
+	}
+	if key, _, ok := g.Newest(MainCache); !ok || key != "c" {
+		t.Fatalf("expected newest key to be c, got %q", key)
+	}
+	if keys := g.Keys(HotCache); !reflect.DeepEqual(keys, []string{"hot"}) {
+		t.Fatalf("unexpected hot keys %v", keys)
+	}
+	n := 0
+	g.Range(MainCache, func(key string, value *ByteView) bool {
+		n++
+		return false
+	})
+	if n != 1 {
+		t.Fatalf("range should stop when fn returns false")
+	}
+}
+
+func TestSubscribeEvictions(t *testing.T) {
+	g := mustGroup(t)(NewGroup("evictions", 2<<10, 2<<7, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	})))
+	defer g.Close()
+
+	var events []EvictionEvent
+	unsubscribe := g.SubscribeEvictions(func(ev EvictionEvent) {
+		events = append(events, ev)
+	})
+	g.hotCache.add("key", NewByteView([]byte("v1"), time.Now().Add(time.Hour)))
+	g.hotCache.add("key", NewByteView([]byte("v2"), time.Now().Add(time.Hour)))
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events))
+	}
+	ev := events[0]
+	if ev.Group != "evictions" || ev.Cache != HotCache || ev.Key != "key" || ev.Value.String() != "v1" || ev.Reason != lru.EvictReplaced {
+		t.Fatalf("unexpected event %+v", ev)
+	}
+	unsubscribe()
+	g.hotCache.add("key", NewByteView([]byte("v3"), time.Now().Add(time.Hour)))
+	if len(events) != 1 {
+		t.Fatalf("expected no events after unsubscribe")
+	}
+}
+
+func TestGetContextCancel(t *testing.T) {
+	cancelled := make(chan struct{})
+	g := mustGroup(t)(NewGroupContext("context", 2<<10, 2<<7, ContextGetterFunc(func(ctx context.Context, key string) ([]byte, error) {
+		<-ctx.Done()
+		close(cancelled)
+		return nil, ctx.Err()
+	})))
+	defer g.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := g.GetContext(ctx, "slow"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatalf("getter was not cancelled after the caller gave up")
+	}
+}
+
+func TestContextDeadline(t *testing.T) {
+	// 第一个调用者很快超时，不能让共享同一次加载的其他调用者一起失败
+	release := make(chan struct{})
+	g := mustGroup(t)(NewGroupContext("deadline", 2<<10, 2<<7, ContextGetterFunc(func(ctx context.Context, key string) ([]byte, error) {
+		<-release
+		return []byte(key), ctx.Err()
+	})))
+	defer g.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	first := make(chan error, 1)
+	go func() {
+		_, err := g.GetContext(ctx, "key")
+		first <- err
+	}()
+	time.Sleep(5 * time.Millisecond)
+	second := make(chan error, 1)
+	go func() {
+		_, err := g.GetContext(context.Background(), "key")
+		second <- err
+	}()
+	if err := <-first; !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the impatient caller to time out, got %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	if err := <-second; err != nil {
+		t.Fatalf("expected the patient caller to get the value, got %v", err)
+	}
+
+	// write-through 写数据源和转发给远端节点的 Set 都能被取消
+	var setterErr error
+	w := mustGroup(t)(NewGroup("deadline-write", 2<<10, 2<<7, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}), WithWriteThrough(SetterFunc(func(ctx context.Context, key string, value []byte) error {
+		<-ctx.Done()
+		setterErr = ctx.Err()
+		return setterErr
+	}), nil)))
+	defer w.Close()
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := w.SetContext(ctx, "key", NewByteView([]byte("v"), time.Time{}), false); !errors.Is(err, context.DeadlineExceeded) || setterErr == nil {
+		t.Fatalf("expected the write to be cancelled, got %v", err)
+	}
+}
+
+// fakePeer 把所有的键都当作属于同一个远端节点
+type fakePeer struct {
+	mu      sync.Mutex
+	values  map[string][]byte
+	deleted []string
+	batches int             // 收到的批量请求数
+	tags    []string        // 收到的按标签失效请求
+	omit    string          // GetMulti 的响应中故意漏掉的键
+	zipped  map[string]bool // 以压缩形式收到的键
+}
+
+func (p *fakePeer) PickPeer(key string) (connect.PeerGetter, bool) {
+	return p, true
+}
+
+func (p *fakePeer) Get(ctx context.Context, group string, key string) (*pb.GetResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if v, ok := p.values[key]; ok {
+		return &pb.GetResponse{Value: v, Compressed: p.zipped[key]}, nil
+	}
+	return nil, fmt.Errorf("%s not exist", key)
+}
+
+func (p *fakePeer) Set(ctx context.Context, group string, key string, value []byte, compressed bool, expire time.Time, ishot bool, tags []string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.values == nil {
+		p.values = make(map[string][]byte)
+		p.zipped = make(map[string]bool)
+	}
+	p.values[key] = value
+	p.zipped[key] = compressed
+	return nil
+}
+
+func (p *fakePeer) Delete(ctx context.Context, group string, key string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.values, key)
+	p.deleted = append(p.deleted, key)
+	return nil
+}
+
+func (p *fakePeer) GetMulti(ctx context.Context, group string, keys []string) ([]*pb.MultiItem, error) {
+	p.mu.Lock()
+	p.batches++
+	p.mu.Unlock()
+	items := make([]*pb.MultiItem, 0, len(keys))
+	for _, key := range keys {
+		if key == p.omit {
+			continue
+		}
+		item := &pb.MultiItem{Key: key}
+		if resp, err := p.Get(ctx, group, key); err != nil {
+			item.Error = err.Error()
+		} else {
+			item.Value = resp.Value
+			item.Compressed = resp.Compressed
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (p *fakePeer) SetMulti(ctx context.Context, group string, items []*pb.MultiItem, ishot bool) ([]*pb.MultiItem, error) {
+	p.mu.Lock()
+	p.batches++
+	p.mu.Unlock()
+	for _, item := range items {
+		p.Set(ctx, group, item.Key, item.Value, item.Compressed, time.Unix(item.Expire, 0), ishot, item.Tags)
+	}
+	return nil, nil
+}
+
+func (p *fakePeer) InvalidateTag(ctx context.Context, group string, tag string) (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tags = append(p.tags, tag)
+	return 0, nil
+}
+
+// prefixPicker 按键的第一个字符选择远端节点，找不到时认为键属于本节点
+type prefixPicker map[byte]*fakePeer
+
+func (p prefixPicker) PickPeer(key string) (connect.PeerGetter, bool) {
+	if peer, ok := p[key[0]]; ok {
+		return peer, true
+	}
+	return nil, false
+}
+
+func (p prefixPicker) Peers() []connect.PeerGetter {
+	peers := make([]connect.PeerGetter, 0, len(p))
+	for _, peer := range p {
+		peers = append(peers, peer)
+	}
+	return peers
+}
+
+func TestRemove(t *testing.T) {
+	g := mustGroup(t)(NewGroup("remove", 2<<10, 2<<7, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	})))
+	defer g.Close()
+	peer := &fakePeer{}
+	g.RegisterPeers(peer)
+
+	expire := time.Now().Add(time.Hour)
+	g.mainCache.add("key", NewByteView([]byte("v"), expire))
+	if err := g.Set("key", NewByteView([]byte("hot"), expire), true); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Remove("key"); err != nil {
+		t.Fatal(err)
+	}
+	if g.Contains("key") {
+		t.Fatalf("expected local copies to be removed")
+	}
+	if !reflect.DeepEqual(peer.deleted, []string{"key"}) {
+		t.Fatalf("expected the owner to be asked to delete key, got %v", peer.deleted)
+	}
+}
+
+func TestGetMultiSetMulti(t *testing.T) {
+	var loads []string
+	g := mustGroup(t)(NewGroup("multi", 2<<10, 2<<7, GetterFunc(func(key string) ([]byte, error) {
+		loads = append(loads, key)
+		if key == "c-missing" {
+			return nil, fmt.Errorf("%s not exist", key)
+		}
+		return []byte(key), nil
+	})))
+	defer g.Close()
+	a, b := &fakePeer{}, &fakePeer{}
+	g.RegisterPeers(prefixPicker{'a': a, 'b': b})
+
+	expire := time.Now().Add(time.Hour)
+	errs := g.SetMulti(context.Background(), map[string]*ByteView{
+		"a1": NewByteView([]byte("A1"), expire),
+		"a2": NewByteView([]byte("A2"), expire),
+		"b1": NewByteView([]byte("B1"), expire),
+		"c1": NewByteView([]byte("C1"), expire),
+	}, false)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected set errors %v", errs)
+	}
+	if a.batches != 1 || b.batches != 1 || len(a.values) != 2 || len(b.values) != 1 {
+		t.Fatalf("expected one batch per peer, got a=%d b=%d", a.batches, b.batches)
+	}
+
+	values, errs := g.GetMulti(context.Background(), []string{"a1", "a2", "a3", "b1", "c1", "c2", "c-missing", "a1"})
+	if a.batches != 2 || b.batches != 2 {
+		t.Fatalf("expected one batch per peer, got a=%d b=%d", a.batches, b.batches)
+	}
+	expect := map[string]string{"a1": "A1", "a2": "A2", "b1": "B1", "c1": "C1", "c2": "c2"}
+	if len(values) != len(expect) {
+		t.Fatalf("expected %d values, got %d", len(expect), len(values))
+	}
+	for key, v := range expect {
+		if values[key] == nil || values[key].String() != v {
+			t.Fatalf("unexpected value for %s", key)
+		}
+	}
+	if len(errs) != 2 || errs["a3"] == nil || errs["c-missing"] == nil {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+	if !reflect.DeepEqual(loads, []string{"c2", "c-missing"}) {
+		t.Fatalf("expected only local misses to be loaded, got %v", loads)
+	}
+
+	// 远端节点漏掉的键要有明确的错误
+	a.omit = "a4"
+	values, errs = g.GetMulti(context.Background(), []string{"a1", "a4"})
+	if values["a1"] == nil || values["a4"] != nil || errs["a4"] == nil {
+		t.Fatalf("expected an error for the omitted key, got %v %v", values, errs)
+	}
+
+	// 远端节点转发过来的批量请求只在本节点处理，不会再转发
+	loads = nil
+	server := &Server{}
+	resp, err := server.GetMulti(context.Background(), &pb.GetMultiRequest{Group: "multi", Keys: []string{"a5"}})
+	if err != nil || len(resp.GetItems()) != 1 || string(resp.GetItems()[0].GetValue()) != "a5" {
+		t.Fatalf("expected a5 to be loaded locally: %v %v", resp, err)
+	}
+	if _, err := server.SetMulti(context.Background(), &pb.SetMultiRequest{Group: "multi", Items: []*pb.MultiItem{{Key: "b9", Value: []byte("B9")}}}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loads, []string{"a5"}) || a.batches != 3 || b.batches != 2 {
+		t.Fatalf("expected no forwarding, loads=%v a=%d b=%d", loads, a.batches, b.batches)
+	}
+	if v, ok := g.mainCache.peek("b9"); !ok || v.String() != "B9" {
+		t.Fatalf("expected b9 to be cached locally")
+	}
+}
+
+func TestGroupOptions(t *testing.T) {
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	})
+	expireOf := func(g *Group, key string) time.Time {
+		var expire time.Time
+		g.mainCache.rangeItems(func(item lru.Item) bool {
+			if item.Key == key {
+				expire = item.Expire
+			}
+			return true
+		})
+		return expire
+	}
+
+	hourly := mustGroup(t)(NewGroup("ttl-hour", 2<<10, 2<<7, getter, WithTTL(time.Hour), WithExpireJitter(0)))
+	defer hourly.Close()
+	forever := mustGroup(t)(NewGroup("ttl-none", 2<<10, 2<<7, getter, WithNoExpiry(), WithHotCacheBytes(2<<10)))
+	defer forever.Close()
+
+	before := time.Now()
+	hourly.Get("key")
+	forever.Get("key")
+	if expire := expireOf(hourly, "key"); expire.Before(before.Add(time.Hour)) || expire.After(time.Now().Add(time.Hour)) {
+		t.Fatalf("expected key to expire in an hour without jitter, got %v", expire.Sub(before))
+	}
+	if expire := expireOf(forever, "key"); !expire.IsZero() {
+		t.Fatalf("expected key to never expire, got %v", expire)
+	}
+	if forever.hotCache.cacheBytes != 2<<10 {
+		t.Fatalf("expected hot cache size to be overridden")
+	}
+}
+
+func TestResultGetter(t *testing.T) {
+	day := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	g := mustGroup(t)(NewGroupResult("result", 2<<10, 2<<7, ResultGetterFunc(func(ctx context.Context, key string) (Result, error) {
+		switch key {
+		case "short":
+			return Result{Value: []byte(key), TTL: 5 * time.Second}, nil
+		case "day":
+			return Result{Value: []byte(key), Expire: day}, nil
+		case "nocache":
+			return Result{Value: []byte(key), NoCache: true}, nil
+		}
+		return Result{Value: []byte(key)}, nil
+	}), WithTTL(time.Minute), WithExpireJitter(0)))
+	defer g.Close()
+
+	before := time.Now()
+	for _, key := range []string{"short", "day", "nocache", "default"} {
+		if v, err := g.Get(key); err != nil || v.String() != key {
+			t.Fatalf("get %s failed: %v", key, err)
+		}
+	}
+	if v, ok := g.Peek("short"); !ok || v.Expire().Sub(before) > 6*time.Second {
+		t.Fatalf("expected short to expire in 5s")
+	}
+	if v, ok := g.Peek("day"); !ok || !v.Expire().Equal(day) {
+		t.Fatalf("expected day to expire at %v", day)
+	}
+	if v, ok := g.Peek("default"); !ok || v.Expire().Sub(before) < 59*time.Second {
+		t.Fatalf("expected default to use the group ttl")
+	}
+	if g.Contains("nocache") {
+		t.Fatalf("expected nocache not to be cached")
+	}
+}
+
+func TestNegativeCache(t *testing.T) {
+	loads := 0
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		loads++
+		return nil, fmt.Errorf("%s: %w", key, ErrNotFound)
+	})
+	g := mustGroup(t)(NewGroup("negative", 2<<10, 2<<7, getter, WithNegativeCache(time.Minute, 1<<10)))
+	defer g.Close()
+
+	for i := 0; i < 3; i++ {
+		if _, err := g.Get("missing"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+	}
+	if loads != 1 {
+		t.Fatalf("expected not found result to be cached, loaded %d times", loads)
+	}
+	if err := toStatus(fmt.Errorf("wrapped: %w", ErrNotFound)); status.Code(err) != codes.NotFound || !isNotFound(err) {
+		t.Fatalf("expected ErrNotFound to be reported as a recognisable NotFound status, got %v", err)
+	}
+	if isNotFound(status.Error(codes.NotFound, "other")) {
+		t.Fatalf("expected a NotFound status without the reason not to mean the key is missing")
+	}
+	g.Remove("missing")
+	g.Get("missing")
+	if loads != 2 {
+		t.Fatalf("expected Remove to clear the negative cache")
+	}
+
+	loads = 0
+	disabled := mustGroup(t)(NewGroup("negative-off", 2<<10, 2<<7, getter, WithNegativeCache(0, 0)))
+	defer disabled.Close()
+	disabled.Get("missing")
+	disabled.Get("missing")
+	if loads != 2 {
+		t.Fatalf("expected negative cache to be disabled, loaded %d times", loads)
+	}
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	var mu sync.Mutex
+	version := 0
+	release := make(chan struct{})
+	g := mustGroup(t)(NewGroup("swr", 2<<10, 2<<7, GetterFunc(func(key string) ([]byte, error) {
+		mu.Lock()
+		version++
+		v := version
+		mu.Unlock()
+		if v > 1 {
+			<-release
+		}
+		return []byte(fmt.Sprintf("v%d", v)), nil
+	}), WithTTL(20*time.Millisecond), WithExpireJitter(0), WithStaleWhileRevalidate(time.Hour)))
+	defer g.Close()
+
+	if v, err := g.Get("key"); err != nil || v.String() != "v1" {
+		t.Fatalf("unexpected first load %v %v", v, err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if v, err := g.Get("key"); err != nil || v.String() != "v1" {
+		t.Fatalf("expected the stale value while refreshing, got %v %v", v, err)
+	}
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for {
+		if v, ok := g.Peek("key"); ok && v.String() == "v2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("background refresh did not replace the stale value")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRefreshAhead(t *testing.T) {
+	var mu sync.Mutex
+	loads := 0
+	g := mustGroup(t)(NewGroup("refresh-ahead", 2<<10, 2<<7, GetterFunc(func(key string) ([]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		loads++
+		return []byte(key), nil
+	}), WithTTL(time.Minute), WithExpireJitter(0), WithRefreshAhead(2*time.Minute)))
+	defer g.Close()
+
+	g.Get("key")
+	g.Get("key")
+	deadline := time.Now().Add(time.Second)
+	for {
+		mu.Lock()
+		n := loads
+		mu.Unlock()
+		if n == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected a hit close to expiry to be refreshed ahead, loaded %d times", n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestStaleIfError(t *testing.T) {
+	var mu sync.Mutex
+	down := false
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if down {
+			return nil, errors.New("db is down")
+		}
+		return []byte(key), nil
+	})
+	g := mustGroup(t)(NewGroup("stale-if-error", 2<<10, 2<<7, getter, WithTTL(20*time.Millisecond), WithExpireJitter(0), WithStaleIfError(time.Hour)))
+	defer g.Close()
+	strict := mustGroup(t)(NewGroup("stale-if-error-off", 2<<10, 2<<7, getter, WithTTL(20*time.Millisecond), WithExpireJitter(0)))
+	defer strict.Close()
+
+	for _, group := range []*Group{g, strict} {
+		if v, err := group.Get("key"); err != nil || v.Stale() {
+			t.Fatalf("unexpected first load %v %v", v, err)
+		}
+	}
+	time.Sleep(30 * time.Millisecond)
+	mu.Lock()
+	down = true
+	mu.Unlock()
+
+	v, err := g.Get("key")
+	if err != nil || v.String() != "key" || !v.Stale() {
+		t.Fatalf("expected a stale value when the getter fails, got %v %v", v, err)
+	}
+	if _, err := strict.Get("key"); err == nil {
+		t.Fatalf("expected the error without stale-if-error")
+	}
+}
+
+func TestHotKeyPromotion(t *testing.T) {
+	g := mustGroup(t)(NewGroup("hot-keys", 2<<10, 2<<7, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}), WithHotKeys(3, time.Minute)))
+	defer g.Close()
+	peer := &fakePeer{values: map[string][]byte{"hot": []byte("H"), "cold": []byte("C")}}
+	g.RegisterPeers(peer)
+
+	g.Get("cold")
+	for i := 0; i < 3; i++ {
+		if v, err := g.Get("hot"); err != nil || v.String() != "H" {
+			t.Fatalf("unexpected value %v %v", v, err)
+		}
+	}
+	if _, ok := g.hotCache.peek("cold"); ok {
+		t.Fatalf("expected cold key not to be promoted")
+	}
+	v, ok := g.hotCache.peek("hot")
+	if !ok || v.String() != "H" {
+		t.Fatalf("expected hot key to be promoted into hotCache")
+	}
+	g.hotCache.rangeItems(func(item lru.Item) bool {
+		if item.Expire.After(time.Now().Add(time.Minute)) {
+			t.Fatalf("expected promoted key to expire within a minute, got %v", item.Expire)
+		}
+		return true
+	})
+}
+
+func TestStats(t *testing.T) {
+	g := mustGroup(t)(NewGroup("stats", 2<<10, 2<<7, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	})))
+	defer g.Close()
+
+	g.Get("a")
+	g.Get("a")
+	g.Set("hot", NewByteView([]byte("h"), time.Now().Add(time.Hour)), true)
+	g.Get("hot")
+
+	st := g.Stats()
+	if st.Gets != 3 || st.MainCacheHits != 1 || st.HotCacheHits != 1 || st.Misses != 1 || st.LocalLoads != 1 {
+		t.Fatalf("unexpected stats %+v", st)
+	}
+	if st.HitRatio < 0.66 || st.HitRatio > 0.67 {
+		t.Fatalf("expected hit ratio 2/3, got %v", st.HitRatio)
+	}
+	if cs := g.CacheStats(MainCache); cs.Items != 1 || cs.Bytes != 2 {
+		t.Fatalf("unexpected main cache stats %+v", cs)
+	}
+	if cs := g.CacheStats(HotCache); cs.Items != 1 || cs.Bytes != 4 {
+		t.Fatalf("unexpected hot cache stats %+v", cs)
+	}
+}
+
+func TestTypedGroup(t *testing.T) {
+	type user struct {
+		Name string
+		Age  int
+	}
+	g := mustGroup(t)(NewGroup("typed", 2<<10, 2<<7, GetterFunc(func(key string) ([]byte, error) {
+		return JSONCodec[user]{}.Encode(user{Name: key, Age: len(key)})
+	})))
+	defer g.Close()
+	users := NewTypedGroup[user](g, JSONCodec[user]{})
+
+	// 缓存中无法解码的值被当作未命中，重新从数据源加载
+	g.mainCache.add("tom", NewByteView([]byte("not json"), time.Now().Add(time.Hour)))
+	if u, err := users.Get(context.Background(), "tom"); err != nil || u != (user{"tom", 3}) {
+		t.Fatalf("expected tom to be reloaded, got %v %v", u, err)
+	}
+	if err := users.Set("amy", user{"amy", 30}, time.Now().Add(time.Hour), false); err != nil {
+		t.Fatal(err)
+	}
+	if u, err := users.Get(context.Background(), "amy"); err != nil || u != (user{"amy", 30}) {
+		t.Fatalf("unexpected value %v %v", u, err)
+	}
+
+	for _, codec := range []Codec[user]{JSONCodec[user]{}, GobCodec[user]{}} {
+		b, err := codec.Encode(user{"bob", 7})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u, err := codec.Decode(b); err != nil || u != (user{"bob", 7}) {
+			t.Fatalf("%T round trip failed: %v %v", codec, u, err)
+		}
+	}
+	b, err := ProtoCodec[*pb.GetRequest]{}.Encode(&pb.GetRequest{Group: "g", Key: "k"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, err := (ProtoCodec[*pb.GetRequest]{}).Decode(b); err != nil || m.GetGroup() != "g" || m.GetKey() != "k" {
+		t.Fatalf("proto round trip failed: %v %v", m, err)
+	}
+}
+
+func TestCompression(t *testing.T) {
+	blob := strings.Repeat(`{"name":"springcache","tags":["a","b"]}`, 50)
+	g := mustGroup(t)(NewGroup("compress", 2<<10, 2<<7, GetterFunc(func(key string) ([]byte, error) {
+		if key == "small" {
+			return []byte(key), nil
+		}
+		return []byte(blob), nil
+	}), WithCompression(nil, 64)))
+	defer g.Close()
+
+	for _, key := range []string{"blob", "small"} {
+		if _, err := g.Get(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	v, ok := g.Peek("blob")
+	if !ok || !v.Compressed() || v.Len() >= len(blob)/5 || v.String() != blob {
+		t.Fatalf("expected blob to be stored compressed")
+	}
+	if v, _ := g.Peek("small"); v.Compressed() {
+		t.Fatalf("expected values below the threshold to be stored raw")
+	}
+	if cs := g.CacheStats(MainCache); cs.Bytes >= int64(len(blob))/5 {
+		t.Fatalf("expected accounting to use the compressed size, got %d bytes", cs.Bytes)
+	}
+
+	// 压缩后的字节原样发送给远端节点，由接收方解压
+	resp, err := (&Server{}).Get(context.Background(), &pb.GetRequest{Group: "compress", Key: "blob"})
+	if err != nil || !resp.GetCompressed() || len(resp.GetValue()) != v.Len() {
+		t.Fatalf("expected compressed bytes on the wire: %v", err)
+	}
+	if got, err := g.peerView(resp); err != nil || string(got.ByteSlice()) != blob {
+		t.Fatalf("expected the receiver to decompress the value: %v", err)
+	}
+
+	// 单个键的 Set 也把压缩后的字节发给所属节点
+	peer := &fakePeer{}
+	g.RegisterPeers(prefixPicker{'r': peer})
+	if err := g.Set("remote", NewByteView([]byte(blob), time.Time{}), false); err != nil {
+		t.Fatal(err)
+	}
+	if !peer.zipped["remote"] || len(peer.values["remote"]) >= len(blob)/5 {
+		t.Fatalf("expected compressed bytes to be sent to the owner")
+	}
+
+	// 远端节点的压缩算法不一致时返回错误，而不是空值
+	other := mustGroup(t)(NewGroup("compress-mismatch", 2<<10, 2<<7, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}), WithCompression(GzipCompressor{}, 64)))
+	defer other.Close()
+	other.RegisterPeers(prefixPicker{'r': peer})
+	if v, err := other.Get("remote"); err == nil {
+		t.Fatalf("expected a decompression error, got %q", v.String())
+	}
+}
+
+// mustGroup 在创建 Group 失败时终止测试
+// mustGroup 返回一个函数，创建 Group 失败时让测试 t 失败，用法为 mustGroup(t)(NewGroup(...))
+func mustGroup(t *testing.T) func(g *Group, err error) *Group {
+	return func(g *Group, err error) *Group {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return g
+	}
+}
+
+func TestGroupLifecycle(t *testing.T) {
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	})
+	g := mustGroup(t)(NewGroup("lifecycle", 2<<10, 2<<7, getter))
+	if _, err := NewGroup("lifecycle", 2<<10, 2<<7, getter); !errors.Is(err, ErrGroupExists) {
+		t.Fatalf("expected ErrGroupExists, got %v", err)
+	}
+	g.Get("key")
+	found := false
+	for _, name := range ListGroups() {
+		found = found || name == "lifecycle"
+	}
+	if !found {
+		t.Fatalf("expected lifecycle in %v", ListGroups())
+	}
+
+	if !DeleteGroup("lifecycle") || DeleteGroup("lifecycle") {
+		t.Fatalf("expected the group to be deleted exactly once")
+	}
+	if GetGroup("lifecycle") != nil || g.CacheStats(MainCache).Items != 0 {
+		t.Fatalf("expected the group to be unregistered and its caches freed")
+	}
+	_, err := (&Server{}).Get(context.Background(), &pb.GetRequest{Group: "lifecycle", Key: "key"})
+	if status.Code(err) != codes.NotFound || isNotFound(err) {
+		t.Fatalf("expected NotFound without the key-not-found reason for an unknown group, got %v", err)
+	}
+	g = mustGroup(t)(NewGroup("lifecycle", 2<<10, 2<<7, getter))
+	g.Close()
+}
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := logger.NewSlog(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	g := mustGroup(t)(NewGroup("logger", 2<<10, 2<<7, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("secret"), nil
+	}), WithLogger(l)))
+	defer g.Close()
+
+	g.Get("key")
+	g.Get("key")
+	out := buf.String()
+	for _, want := range []string{"level=DEBUG msg=\"cache miss\" group=logger key=key", "msg=\"cache hit\""} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in log output:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "secret") {
+		t.Fatalf("expected values not to be logged")
+	}
+}
+
+// memStore 是记录写操作的数据源
+type memStore struct {
+	mu      sync.Mutex
+	values  map[string]string
+	writes  int
+	deleted []string
+	fail    map[string]bool
+	delay   time.Duration // 每次写之前等待的时间，模拟慢的数据源
+}
+
+func (s *memStore) Set(ctx context.Context, key string, value []byte) error {
+	time.Sleep(s.delay)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail[key] {
+		return fmt.Errorf("write %s failed", key)
+	}
+	if s.values == nil {
+		s.values = make(map[string]string)
+	}
+	s.values[key] = string(value)
+	s.writes++
+	return nil
+}
+
+func (s *memStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.values, key)
+	s.deleted = append(s.deleted, key)
+	return nil
+}
+
+func (s *memStore) get(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.values[key]
+	return v, ok
+}
+
+func TestWriteThrough(t *testing.T) {
+	store := &memStore{fail: map[string]bool{"bad": true}}
+	g := mustGroup(t)(NewGroup("write-through", 2<<10, 2<<7, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}), WithWriteThrough(store, store)))
+	defer g.Close()
+
+	expire := time.Now().Add(time.Hour)
+	if err := g.Set("good", NewByteView([]byte("v"), expire), false); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := store.get("good"); !ok || v != "v" || !g.Contains("good") {
+		t.Fatalf("expected good to be written to the store and the cache")
+	}
+	if err := g.Set("bad", NewByteView([]byte("v"), expire), false); err == nil || g.Contains("bad") {
+		t.Fatalf("expected a failed store write to leave the cache untouched")
+	}
+	g.Remove("good")
+	if _, ok := store.get("good"); ok || g.Contains("good") {
+		t.Fatalf("expected good to be deleted from the store and the cache")
+	}
+}
+
+func TestWriteBehind(t *testing.T) {
+	store := &memStore{}
+	g := mustGroup(t)(NewGroup("write-behind", 2<<10, 2<<7, GetterFunc(func(key string) ([]byte, error) {
+		if v, ok := store.get(key); ok {
+			return []byte(v), nil
+		}
+		return []byte(key), nil
+	}), WithWriteBehind(store, store, time.Hour, 3)))
+	defer g.Close()
+
+	expire := time.Now().Add(time.Hour)
+	for _, v := range []string{"1", "2", "3"} {
+		g.Set("a", NewByteView([]byte(v), expire), false)
+	}
+	g.Remove("b")
+	if _, ok := store.get("a"); ok {
+		t.Fatalf("expected writes to be deferred")
+	}
+	if err := g.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := store.get("a"); v != "3" || store.writes != 1 || !reflect.DeepEqual(store.deleted, []string{"b"}) {
+		t.Fatalf("expected writes to be coalesced, got a=%s writes=%d deleted=%v", v, store.writes, store.deleted)
+	}
+
+	// 脏键被淘汰后由后台协程立即写回，写数据源时不持有缓存的锁
+	store.delay = 300 * time.Millisecond
+	big := strings.Repeat("x", 1500)
+	g.Set("x", NewByteView([]byte(big), expire), false)
+	g.Set("y", NewByteView([]byte(big), expire), false)
+	if g.Contains("x") {
+		t.Fatalf("expected x to be evicted")
+	}
+	start := time.Now()
+	if _, err := g.Get("y"); err != nil || time.Since(start) > 100*time.Millisecond {
+		t.Fatalf("expected reads not to wait for the store, took %v: %v", time.Since(start), err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		if v, ok := store.get("x"); ok && v == big {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected x to be flushed after eviction")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, ok := store.get("y"); ok {
+		t.Fatalf("expected y to wait for the periodic flush")
+	}
+
+	// 属于其他节点的键由调用 Set 的节点写回
+	store.delay = 0
+	peer := &fakePeer{}
+	g.RegisterPeers(prefixPicker{'r': peer})
+	g.Set("r1", NewByteView([]byte("remote"), expire), false)
+	if string(peer.values["r1"]) != "remote" || g.Contains("r1") {
+		t.Fatalf("expected r1 to be cached on its owner")
+	}
+	if err := g.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := store.get("r1"); v != "remote" {
+		t.Fatalf("expected the peer-owned key to be written back by this node")
+	}
+
+	// 被淘汰的脏键在写回完成之前被读取时，读到的仍是自己写入的值
+	store.delay = 300 * time.Millisecond
+	g.Set("w", NewByteView([]byte(big), expire), false)
+	g.Set("z", NewByteView([]byte(big), expire), false)
+	if g.Contains("w") {
+		t.Fatalf("expected w to be evicted")
+	}
+	if v, err := g.Get("w"); err != nil || v.String() != big {
+		t.Fatalf("expected to read back the pending write of w, got %v", err)
+	}
+}
+
+func TestGeneration(t *testing.T) {
+	var mu sync.Mutex
+	loads := map[string]int{}
+	g := mustGroup(t)(NewGroup("generation", 2<<10, 2<<7, GetterFunc(func(key string) ([]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		loads[key]++
+		if key == "missing" {
+			return nil, ErrNotFound
+		}
+		return []byte(key), nil
+	})))
+	defer g.Close()
+
+	expire := time.Now().Add(time.Hour)
+	g.Get("old")
+	g.Get("key")
+	g.Get("missing")
+	g.Set("hot", NewByteView([]byte("h"), expire), true)
+	if !g.SetGeneration(1) || g.SetGeneration(1) || g.Generation() != 1 {
+		t.Fatalf("expected the generation to only move forward")
+	}
+	if g.Contains("key") || g.Contains("hot") || len(g.Keys(MainCache)) != 0 {
+		t.Fatalf("expected old entries to be unreachable after a bump")
+	}
+	if _, _, ok := g.Oldest(MainCache); ok {
+		t.Fatalf("expected Oldest to skip entries of the old generation")
+	}
+	if _, _, ok := g.Newest(HotCache); ok {
+		t.Fatalf("expected Newest to skip entries of the old generation")
+	}
+	g.Get("key")
+	g.Get("missing")
+	if loads["key"] != 2 || loads["missing"] != 2 {
+		t.Fatalf("expected every key to be reloaded, got %v", loads)
+	}
+	g.Get("key")
+	if loads["key"] != 2 || !g.Contains("key") {
+		t.Fatalf("expected reloaded values to be cached in the new generation")
+	}
+	if key, _, ok := g.Oldest(MainCache); !ok || key != "key" {
+		t.Fatalf("expected the oldest current key to be key, got %q", key)
+	}
+	if _, err := (&Server{}).BumpGeneration(context.Background(), "generation"); !errors.Is(err, ErrorNoEtcd) {
+		t.Fatalf("expected ErrorNoEtcd without etcd, got %v", err)
+	}
+}
+
+func TestTags(t *testing.T) {
+	peer := &fakePeer{}
+	g := mustGroup(t)(NewGroupResult("tags", 2<<10, 2<<7, ResultGetterFunc(func(ctx context.Context, key string) (Result, error) {
+		return Result{Value: []byte(key), Tags: []string{"user:" + key[:1]}}, nil
+	}), WithTTL(time.Minute)))
+	g.RegisterPeers(prefixPicker{'r': peer})
+	defer g.Close()
+
+	for _, key := range []string{"a1", "a2", "b1"} {
+		if _, err := g.Get(key); err != nil {
+			t.Fatalf("get %s failed: %v", key, err)
+		}
+	}
+	if err := g.Set("c1", NewByteView([]byte("c1"), time.Time{}), false, "user:a"); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	if err := g.Set("h1", NewByteView([]byte("h1"), time.Time{}), true, "user:a"); err != nil {
+		t.Fatalf("set hot failed: %v", err)
+	}
+	if err := g.Set("r1", NewByteView([]byte("r1"), time.Time{}), false, "user:a"); err != nil {
+		t.Fatalf("set on peer failed: %v", err)
+	}
+
+	if err := g.InvalidateTag("user:a"); err != nil {
+		t.Fatalf("invalidate tag failed: %v", err)
+	}
+	for _, key := range []string{"a1", "a2", "c1"} {
+		if _, ok := g.mainCache.peek(key); ok {
+			t.Fatalf("%s should be removed from mainCache", key)
+		}
+	}
+	if _, ok := g.hotCache.peek("h1"); ok {
+		t.Fatalf("h1 should be removed from hotCache")
+	}
+	if _, ok := g.mainCache.peek("b1"); !ok {
+		t.Fatalf("b1 does not carry the tag and should be kept")
+	}
+	if !reflect.DeepEqual(peer.tags, []string{"user:a"}) {
+		t.Fatalf("peer should be notified, got %v", peer.tags)
+	}
+
+	// 被替换的值不再带有旧标签
+	g.Set("b1", NewByteView([]byte("b1"), time.Time{}), false, "other")
+	g.InvalidateTag("user:b")
+	if _, ok := g.mainCache.peek("b1"); !ok {
+		t.Fatalf("b1 was replaced without the tag and should be kept")
+	}
+	if got := len(g.mainCache.tags); got != 1 {
+		t.Fatalf("tag index should only hold the live tag, got %d", got)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next ResultGetter) ResultGetter {
+			return ResultGetterFunc(func(ctx context.Context, key string) (Result, error) {
+				calls = append(calls, name)
+				return next.GetResult(ctx, key)
+			})
+		}
+	}
+	rewrite := func(next ResultGetter) ResultGetter {
+		return ResultGetterFunc(func(ctx context.Context, key string) (Result, error) {
+			return next.GetResult(ctx, strings.ToUpper(key))
+		})
+	}
+	failures := 2
+	g := mustGroup(t)(NewGroup("middleware", 2<<10, 2<<7, GetterFunc(func(key string) ([]byte, error) {
+		calls = append(calls, "getter")
+		if failures > 0 {
+			failures--
+			return nil, errors.New("flaky")
+		}
+		return []byte(key), nil
+	}), WithMiddleware(trace("outer"), rewrite), WithMiddleware(trace("inner"), RetryMiddleware(2, time.Millisecond))))
+	defer g.Close()
+
+	v, err := g.Get("key")
+	if err != nil || v.String() != "KEY" {
+		t.Fatalf("expected the rewritten key, got %v %v", v, err)
+	}
+	want := []string{"outer", "inner", "getter", "getter", "getter"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("expected %v, got %v", want, calls)
+	}
+
+	// 数据源确认不存在的键不重试
+	calls = nil
+	nf := mustGroup(t)(NewGroup("middleware-notfound", 2<<10, 2<<7, GetterFunc(func(key string) ([]byte, error) {
+		calls = append(calls, "getter")
+		return nil, ErrNotFound
+	}), WithMiddleware(RetryMiddleware(3, time.Millisecond))))
+	defer nf.Close()
+	if _, err := nf.Get("missing"); !errors.Is(err, ErrNotFound) || len(calls) != 1 {
+		t.Fatalf("expected a single call for a missing key, got %d calls: %v", len(calls), err)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	opts := []GroupOption{WithExpireJitter(0), WithCompression(nil, 8)}
+	src := mustGroup(t)(NewGroup("snapshot-src", 8<<10, 8<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}), opts...))
+	defer src.Close()
+	long := strings.Repeat("v", 1<<10)
+	src.Set("keep", NewByteView([]byte(long), time.Now().Add(time.Hour)), false, "t")
+	src.Set("forever", NewByteView([]byte("forever"), time.Time{}), false)
+	src.Set("hot", NewByteView([]byte("hot"), time.Now().Add(time.Hour)), true)
+	src.Set("short", NewByteView([]byte("short"), time.Now().Add(50*time.Millisecond)), false)
+
+	var buf bytes.Buffer
+	if err := src.Snapshot(&buf); err != nil {
+		t.Fatalf("snapshot failed: %v", err)
+	}
+	data := buf.Bytes()
+	time.Sleep(100 * time.Millisecond)
+
+	dst := mustGroup(t)(NewGroup("snapshot-dst", 8<<10, 8<<10, GetterFunc(func(key string) ([]byte, error) {
+		return nil, ErrNotFound
+	}), opts...))
+	defer dst.Close()
+	if err := dst.Restore(bytes.NewReader(data)); err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+	keep, ok := dst.mainCache.peek("keep")
+	if !ok || keep.String() != long || !keep.Compressed() || !reflect.DeepEqual(keep.Tags(), []string{"t"}) {
+		t.Fatalf("keep was not restored with its value and tags")
+	}
+	if want, _ := src.mainCache.peek("keep"); !keep.Expire().Equal(want.Expire()) {
+		t.Fatalf("expected the absolute expiry %v, got %v", want.Expire(), keep.Expire())
+	}
+	if v, ok := dst.mainCache.peek("forever"); !ok || !v.Expire().IsZero() {
+		t.Fatalf("forever should be restored without expiry")
+	}
+	if _, ok := dst.hotCache.peek("hot"); !ok {
+		t.Fatalf("hot should be restored into hotCache")
+	}
+	if _, ok := dst.mainCache.peek("short"); ok {
+		t.Fatalf("expired entries should be skipped on restore")
+	}
+	if err := dst.InvalidateTag("t"); err != nil || dst.Contains("keep") {
+		t.Fatalf("restored tags should be indexed: %v", err)
+	}
+
+	// 快照带着代数，只有代数与 Group 当前的代数相同时才读回，Restore 不会改变 Group 的代数
+	src.SetGeneration(3)
+	src.Set("gen", NewByteView([]byte("gen"), time.Time{}), false)
+	var genBuf bytes.Buffer
+	if err := src.Snapshot(&genBuf); err != nil {
+		t.Fatal(err)
+	}
+	fresh := mustGroup(t)(NewGroup("snapshot-gen", 8<<10, 8<<10, GetterFunc(func(key string) ([]byte, error) {
+		return nil, ErrNotFound
+	})))
+	defer fresh.Close()
+	if err := fresh.Restore(bytes.NewReader(genBuf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if fresh.Generation() != 0 || fresh.CacheStats(MainCache).Items != 0 {
+		t.Fatalf("expected a snapshot of a newer generation to be dropped without changing the generation")
+	}
+	fresh.SetGeneration(3)
+	if err := fresh.Restore(bytes.NewReader(genBuf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if fresh.Generation() != 3 || !fresh.Contains("gen") {
+		t.Fatalf("expected the snapshot to be restored in generation 3")
+	}
+	// Group 的代数已经超过快照的代数时，快照中的值都已经失效
+	fresh.Remove("gen")
+	fresh.SetGeneration(4)
+	if err := fresh.Restore(bytes.NewReader(genBuf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if fresh.Contains("gen") || fresh.CacheStats(MainCache).Items != 0 {
+		t.Fatalf("expected an outdated snapshot to be dropped")
+	}
+
+	bad := append([]byte(nil), data...)
+	bad[len(snapshotMagic)] = snapshotVersion + 1
+	if err := dst.Restore(bytes.NewReader(bad)); !errors.Is(err, ErrSnapshotVersion) {
+		t.Fatalf("expected ErrSnapshotVersion, got %v", err)
+	}
+	if err := dst.Restore(bytes.NewReader(data[:len(data)-3])); !errors.Is(err, ErrSnapshotFormat) {
+		t.Fatalf("expected ErrSnapshotFormat for truncated data, got %v", err)
+	}
+
+	// Server 把所有 Group 保存到一个文件，读回时跳过不存在的 Group
+	s := NewServer("snapshot", "127.0.0.1:0", nil, WithSnapshot(filepath.Join(t.TempDir(), "cache.snap"), 0))
+	if err := s.LoadSnapshot(); err != nil {
+		t.Fatalf("missing snapshot file should be ignored: %v", err)
+	}
+	if err := s.SaveSnapshot(); err != nil {
+		t.Fatalf("save snapshot failed: %v", err)
+	}
+	src.Remove("gen")
+	if err := s.LoadSnapshot(); err != nil {
+		t.Fatalf("load snapshot failed: %v", err)
+	}
+	if v, ok := src.Peek("gen"); !ok || v.String() != "gen" {
+		t.Fatalf("gen should be restored from the server snapshot")
+	}
+}
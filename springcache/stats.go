@@ -0,0 +1,134 @@
+package springcache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// DefaultStatsWindow 是计算命中率的时间窗口
+var DefaultStatsWindow = time.Minute
+
+// statsBuckets 是命中率窗口被分成的桶数，窗口随时间按桶滑动
+const statsBuckets = 6
+
+// Stats 是 Group 的统计信息快照
+type Stats struct {
+	Gets          int64 // 所有的 Get 请求，批量请求中的每个键各算一次
+	MainCacheHits int64 // 命中 mainCache 的请求，包括宽限期内返回的旧值
+	HotCacheHits  int64 // 命中 hotCache 的请求
+	NegativeHits  int64 // 命中负缓存的请求
+	Misses        int64 // 没有命中任何缓存，需要加载的请求
+	LocalLoads    int64 // 从本地数据源加载成功的次数
+	PeerLoads     int64 // 从远端节点加载成功的次数
+	PeerErrors    int64 // 从远端节点加载失败的次数
+	Dedups        int64 // 被 singleflight 合并到其他请求上的加载次数
+	Evictions     int64 // mainCache 和 hotCache 因为内存不足或过期淘汰的键值对个数
+
+	HitRatio float64 // 最近 DefaultStatsWindow 内的命中率，没有请求时为 0
+}
+
+// CacheStats 是单个缓存的统计信息快照
+type CacheStats struct {
+	Bytes     int64 // 占用的内存
+	Items     int64 // 键值对个数
+	Evictions int64 // 因为内存不足或过期被淘汰的键值对个数
+}
+
+// groupStats 是 Group 内部使用的计数器，可以被并发修改
+type groupStats struct {
+	gets          atomic.Int64
+	mainCacheHits atomic.Int64
+	hotCacheHits  atomic.Int64
+	negativeHits  atomic.Int64
+	misses        atomic.Int64
+	localLoads    atomic.Int64
+	peerLoads     atomic.Int64
+	peerErrors    atomic.Int64
+	dedups        atomic.Int64
+
+	window hitWindow
+}
+
+// hitWindow 按时间分桶统计请求数和命中数，用于计算最近一段时间的命中率。
+// 桶的切换没有加锁，统计结果是近似的
+type hitWindow struct {
+	buckets [statsBuckets]struct {
+		epoch atomic.Int64 // 桶当前对应的时间段
+		gets  atomic.Int64
+		hits  atomic.Int64
+	}
+}
+
+// span 返回每个桶覆盖的时间
+func (w *hitWindow) span() int64 {
+	span := int64(DefaultStatsWindow) / statsBuckets
+	if span <= 0 {
+		span = 1
+	}
+	return span
+}
+
+// record 记录一次请求是否命中
+func (w *hitWindow) record(hit bool) {
+	epoch := time.Now().UnixNano() / w.span()
+	b := &w.buckets[epoch%statsBuckets]
+	if old := b.epoch.Load(); old != epoch && b.epoch.CompareAndSwap(old, epoch) {
+		b.gets.Store(0)
+		b.hits.Store(0)
+	}
+	b.gets.Add(1)
+	if hit {
+		b.hits.Add(1)
+	}
+}
+
+// ratio 返回窗口内的命中率
+func (w *hitWindow) ratio() float64 {
+	epoch := time.Now().UnixNano() / w.span()
+	var gets, hits int64
+	for i := range w.buckets {
+		b := &w.buckets[i]
+		if epoch-b.epoch.Load() < statsBuckets {
+			gets += b.gets.Load()
+			hits += b.hits.Load()
+		}
+	}
+	if gets == 0 {
+		return 0
+	}
+	return float64(hits) / float64(gets)
+}
+
+// recordGet 记录一次请求的结果
+func (s *groupStats) recordGet(hit bool) {
+	s.gets.Add(1)
+	if !hit {
+		s.misses.Add(1)
+	}
+	s.window.record(hit)
+}
+
+// Stats 返回 Group 的统计信息
+func (g *Group) Stats() Stats {
+	return Stats{
+		Gets:          g.stats.gets.Load(),
+		MainCacheHits: g.stats.mainCacheHits.Load(),
+		HotCacheHits:  g.stats.hotCacheHits.Load(),
+		NegativeHits:  g.stats.negativeHits.Load(),
+		Misses:        g.stats.misses.Load(),
+		LocalLoads:    g.stats.localLoads.Load(),
+		PeerLoads:     g.stats.peerLoads.Load(),
+		PeerErrors:    g.stats.peerErrors.Load(),
+		Dedups:        g.stats.dedups.Load(),
+		Evictions:     g.mainCache.evictions.Load() + g.hotCache.evictions.Load(),
+		HitRatio:      g.stats.window.ratio(),
+	}
+}
+
+// CacheStats 返回 kind 对应缓存的统计信息，kind 无效时返回零值
+func (g *Group) CacheStats(kind CacheType) CacheStats {
+	if c := g.cache(kind); c != nil {
+		return c.stats()
+	}
+	return CacheStats{}
+}
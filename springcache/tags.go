@@ -0,0 +1,43 @@
+package springcache
+
+import (
+	"SpringCache/connect"
+	"context"
+	"errors"
+)
+
+// 标签：值可以带有若干个标签，每个缓存维护标签到键的反向索引，
+// InvalidateTag 删除带有某个标签的所有键，适合一次失效一批相关的键
+
+// InvalidateTag 删除本地和所有远端节点中带有 tag 的键
+func (g *Group) InvalidateTag(tag string) error {
+	return g.InvalidateTagContext(context.Background(), tag)
+}
+
+// InvalidateTagContext 与 InvalidateTag 相同，ctx 用于控制对远端节点的请求。
+// 只有 PeerPicker 同时实现了 connect.PeerLister 时才会通知远端节点，
+// 通知失败的节点的错误会合并后返回，不影响其他节点
+func (g *Group) InvalidateTagContext(ctx context.Context, tag string) error {
+	if tag == "" {
+		return errors.New("tag is empty")
+	}
+	removed := g.removeTagLocally(tag)
+	g.logger.Debug("invalidate tag", "group", g.name, "tag", tag, "removed", removed)
+	lister, ok := g.peers.(connect.PeerLister)
+	if !ok {
+		return nil
+	}
+	var errs []error
+	for _, peer := range lister.Peers() {
+		if _, err := peer.InvalidateTag(ctx, g.name, tag); err != nil {
+			g.logger.Warn("invalidate tag on peer failed", "group", g.name, "tag", tag, "err", err)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// removeTagLocally 只删除本地 mainCache 和 hotCache 中带有 tag 的键，返回删除的个数
+func (g *Group) removeTagLocally(tag string) int {
+	return g.mainCache.removeTag(tag) + g.hotCache.removeTag(tag)
+}
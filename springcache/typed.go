@@ -0,0 +1,61 @@
+package springcache
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// TypedGroup 在 Group 上封装了类型为 T 的值的读写，值用 Codec 编码后存储在 Group 中
+type TypedGroup[T any] struct {
+	group *Group
+	codec Codec[T]
+}
+
+// NewTypedGroup 用 codec 在 g 上创建一个 TypedGroup，g 的数据源需要返回 codec 编码后的字节
+func NewTypedGroup[T any](g *Group, codec Codec[T]) *TypedGroup[T] {
+	if g == nil {
+		panic("springcache: group is nil")
+	}
+	if codec == nil {
+		panic("springcache: codec is nil")
+	}
+	return &TypedGroup[T]{group: g, codec: codec}
+}
+
+// Group 返回底层的 Group
+func (t *TypedGroup[T]) Group() *Group {
+	return t.group
+}
+
+// Get 获取 key 对应的值并解码。缓存中的值解码失败时被当作未命中：
+// 删除本地的副本后重新加载一次，重新加载的值仍然无法解码时才返回错误
+func (t *TypedGroup[T]) Get(ctx context.Context, key string) (T, error) {
+	var zero T
+	view, err := t.group.GetContext(ctx, key)
+	if err != nil {
+		return zero, err
+	}
+	if v, err := t.codec.Decode(view.data()); err == nil {
+		return v, nil
+	}
+	t.group.removeLocally(key)
+	view, err = t.group.LoadContext(ctx, key)
+	if err != nil {
+		return zero, err
+	}
+	v, err := t.codec.Decode(view.data())
+	if err != nil {
+		return zero, fmt.Errorf("springcache: decode %s/%s: %w", t.group.name, key, err)
+	}
+	return v, nil
+}
+
+// Set 编码 value 后设置到缓存中，expire 为零值时表示永不过期
+func (t *TypedGroup[T]) Set(key string, value T, expire time.Time, ishot bool) error {
+	b, err := t.codec.Encode(value)
+	if err != nil {
+		return fmt.Errorf("springcache: encode %s/%s: %w", t.group.name, key, err)
+	}
+	return t.group.Set(key, NewByteView(b, expire), ishot)
+}
@@ -0,0 +1,255 @@
+package springcache
+
+import (
+	"SpringCache/logger"
+	"context"
+	"sync"
+	"time"
+)
+
+// 写回数据源：Group.Set 和 Group.Remove 可以同时修改数据源。
+// write-through 模式下先写数据源，成功后才更新缓存；
+// write-behind 模式下先更新缓存，写操作放入队列，同一个键的多次写合并为最后一次，
+// 由后台协程批量写回并在失败时重试。脏键被淘汰或过期时，后台协程会立即单独写回它，
+// 缩短之后的未命中从数据源读到旧值的时间。还没有写回完成的键未命中时直接使用等待写回的值，
+// 不会从数据源读到旧值。
+// 写操作记录在调用 Set 的节点上，键属于其他节点时，所属节点淘汰这个键不会触发立即写回，
+// 只能等调用 Set 的节点按间隔写回
+
+// evictedQueueSize 是等待立即写回的被淘汰脏键的队列长度，队列满时脏键等下一次定期写回
+const evictedQueueSize = 1024
+
+// DefaultWriteBehindInterval 是 write-behind 模式下默认的写回间隔
+var DefaultWriteBehindInterval = time.Second
+
+// WriteMode 决定 Group.Set 和 Group.Remove 如何写回数据源
+type WriteMode int
+
+const (
+	// WriteNone 只修改缓存，不写数据源
+	WriteNone WriteMode = iota
+	// WriteThrough 同步写数据源，写成功后才修改缓存
+	WriteThrough
+	// WriteBehind 先修改缓存，再由后台协程异步写数据源
+	WriteBehind
+)
+
+// pendingWrite 是一个等待写回的操作，deleted 为 true 时表示删除
+type pendingWrite struct {
+	value    []byte
+	deleted  bool
+	attempts int // 已经失败的次数
+}
+
+// writeBehind 保存等待写回的操作，每个键只保留最后一次操作
+type writeBehind struct {
+	setter   Setter
+	deleter  Deleter
+	interval time.Duration
+	retries  int // 每个操作最多重试的次数
+	logger   logger.Logger
+
+	mu      sync.Mutex
+	pending map[string]*pendingWrite
+	writing map[string]*pendingWrite // 正在写回的操作，写回结束前数据源中可能还是旧值
+	flushMu sync.Mutex               // 保证同一时间只有一次写回，写回的顺序与入队的顺序一致
+	evicted chan string              // 被淘汰的脏键，由 run 在缓存的锁之外写回
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func newWriteBehind(setter Setter, deleter Deleter, interval time.Duration, retries int) *writeBehind {
+	if interval <= 0 {
+		interval = DefaultWriteBehindInterval
+	}
+	return &writeBehind{
+		setter:   setter,
+		deleter:  deleter,
+		interval: interval,
+		retries:  retries,
+		logger:   logger.Discard,
+		pending:  make(map[string]*pendingWrite),
+		evicted:  make(chan string, evictedQueueSize),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// enqueue 把对 key 的写操作放入队列，覆盖之前还没有写回的操作
+func (w *writeBehind) enqueue(key string, op *pendingWrite) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending[key] = op
+}
+
+// dirty 判断 key 是否有还没有写回的操作
+func (w *writeBehind) dirty(key string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, ok := w.pending[key]
+	return ok
+}
+
+// lookup 返回 key 还没有写回完成的最后一次操作，包括正在写回的操作
+func (w *writeBehind) lookup(key string) (*pendingWrite, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if op, ok := w.pending[key]; ok {
+		return op, true
+	}
+	op, ok := w.writing[key]
+	return op, ok
+}
+
+// run 定期写回所有等待的操作，stop 关闭后写回剩下的操作再退出
+func (w *writeBehind) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.flush(context.Background())
+		case key := <-w.evicted:
+			if err := w.flushKey(context.Background(), key); err != nil {
+				w.logger.Warn("flush evicted key failed", "key", key, "err", err)
+			}
+		case <-w.stop:
+			w.flush(context.Background())
+			return
+		}
+	}
+}
+
+// close 停止后台协程并写回剩下的操作，可以重复调用
+func (w *writeBehind) close() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+	<-w.done
+}
+
+// flush 写回当前所有等待的操作，失败的操作在没有被新的写覆盖时重新入队，
+// 超过重试次数后被丢弃。返回最后一个错误
+func (w *writeBehind) flush(ctx context.Context) error {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+	w.mu.Lock()
+	batch := w.pending
+	w.pending = make(map[string]*pendingWrite)
+	w.writing = batch
+	w.mu.Unlock()
+	defer w.doneWriting()
+
+	var lastErr error
+	for key, op := range batch {
+		if err := w.write(ctx, key, op); err != nil {
+			lastErr = err
+			w.retry(key, op, err)
+		}
+	}
+	return lastErr
+}
+
+// doneWriting 在一次写回结束后清除正在写回的操作
+func (w *writeBehind) doneWriting() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.writing = nil
+}
+
+// flushKey 同步写回 key 等待的操作，没有等待的操作时什么也不做
+func (w *writeBehind) flushKey(ctx context.Context, key string) error {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+	w.mu.Lock()
+	op, ok := w.pending[key]
+	delete(w.pending, key)
+	if ok {
+		w.writing = map[string]*pendingWrite{key: op}
+	}
+	w.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	defer w.doneWriting()
+	err := w.write(ctx, key, op)
+	if err != nil {
+		w.retry(key, op, err)
+	}
+	return err
+}
+
+func (w *writeBehind) write(ctx context.Context, key string, op *pendingWrite) error {
+	if op.deleted {
+		if w.deleter == nil {
+			return nil
+		}
+		return w.deleter.Delete(ctx, key)
+	}
+	return w.setter.Set(ctx, key, op.value)
+}
+
+// retry 把失败的操作重新入队，key 已经有新的操作时丢弃旧的操作
+func (w *writeBehind) retry(key string, op *pendingWrite, err error) {
+	op.attempts++
+	if op.attempts > w.retries {
+		w.logger.Error("write behind gave up", "key", key, "attempts", op.attempts, "err", err)
+		return
+	}
+	w.logger.Warn("write behind failed, will retry", "key", key, "attempts", op.attempts, "err", err)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.pending[key]; !ok {
+		w.pending[key] = op
+	}
+}
+
+// writeStore 按写模式把 key 的新值写回数据源
+func (g *Group) writeStore(ctx context.Context, key string, value *ByteView) error {
+	switch g.writeMode {
+	case WriteThrough:
+		return g.setter.Set(ctx, key, value.ByteSlice())
+	case WriteBehind:
+		g.writer.enqueue(key, &pendingWrite{value: value.ByteSlice()})
+	}
+	return nil
+}
+
+// deleteStore 按写模式从数据源中删除 key，没有设置 Deleter 时什么也不做
+func (g *Group) deleteStore(ctx context.Context, key string) error {
+	if g.deleter == nil {
+		return nil
+	}
+	switch g.writeMode {
+	case WriteThrough:
+		return g.deleter.Delete(ctx, key)
+	case WriteBehind:
+		g.writer.enqueue(key, &pendingWrite{deleted: true})
+	}
+	return nil
+}
+
+// flushEvicted 在 mainCache 中的脏键被淘汰或过期时让后台协程立即写回它。
+// 它在缓存分片的锁内被调用，所以只记录键，不等待数据源
+func (g *Group) flushEvicted(key string) {
+	if g.writer == nil || !g.writer.dirty(key) {
+		return
+	}
+	select {
+	case g.writer.evicted <- key:
+	default:
+		g.logger.Debug("evicted queue full, key waits for the next flush", "group", g.name, "key", key)
+	}
+}
+
+// Flush 立即把 write-behind 队列中所有等待的操作写回数据源，返回最后一个失败的错误。
+// 失败的操作仍会按重试次数在之后重试
+func (g *Group) Flush(ctx context.Context) error {
+	if g.writer == nil {
+		return nil
+	}
+	return g.writer.flush(ctx)
+}
@@ -24,6 +24,10 @@ const _ = grpc.SupportPackageIsVersion7
 type SpringCacheClient interface {
 	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error)
 	Set(ctx context.Context, in *SetRequest, opts ...grpc.CallOption) (*SetResponse, error)
+	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+	GetMulti(ctx context.Context, in *GetMultiRequest, opts ...grpc.CallOption) (*GetMultiResponse, error)
+	SetMulti(ctx context.Context, in *SetMultiRequest, opts ...grpc.CallOption) (*SetMultiResponse, error)
+	InvalidateTag(ctx context.Context, in *InvalidateTagRequest, opts ...grpc.CallOption) (*InvalidateTagResponse, error)
 }
 
 type springCacheClient struct {
@@ -52,12 +56,52 @@ func (c *springCacheClient) Set(ctx context.Context, in *SetRequest, opts ...grp
 	return out, nil
 }
 
+func (c *springCacheClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, "/springcachepb.SpringCache/Delete", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *springCacheClient) GetMulti(ctx context.Context, in *GetMultiRequest, opts ...grpc.CallOption) (*GetMultiResponse, error) {
+	out := new(GetMultiResponse)
+	err := c.cc.Invoke(ctx, "/springcachepb.SpringCache/GetMulti", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *springCacheClient) SetMulti(ctx context.Context, in *SetMultiRequest, opts ...grpc.CallOption) (*SetMultiResponse, error) {
+	out := new(SetMultiResponse)
+	err := c.cc.Invoke(ctx, "/springcachepb.SpringCache/SetMulti", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *springCacheClient) InvalidateTag(ctx context.Context, in *InvalidateTagRequest, opts ...grpc.CallOption) (*InvalidateTagResponse, error) {
+	out := new(InvalidateTagResponse)
+	err := c.cc.Invoke(ctx, "/springcachepb.SpringCache/InvalidateTag", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // SpringCacheServer is the server API for SpringCache service.
 // All implementations must embed UnimplementedSpringCacheServer
 // for forward compatibility
 type SpringCacheServer interface {
 	Get(context.Context, *GetRequest) (*GetResponse, error)
 	Set(context.Context, *SetRequest) (*SetResponse, error)
+	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
+	GetMulti(context.Context, *GetMultiRequest) (*GetMultiResponse, error)
+	SetMulti(context.Context, *SetMultiRequest) (*SetMultiResponse, error)
+	InvalidateTag(context.Context, *InvalidateTagRequest) (*InvalidateTagResponse, error)
 	mustEmbedUnimplementedSpringCacheServer()
 }
 
@@ -71,6 +115,18 @@ func (UnimplementedSpringCacheServer) Get(context.Context, *GetRequest) (*GetRes
 func (UnimplementedSpringCacheServer) Set(context.Context, *SetRequest) (*SetResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Set not implemented")
 }
+func (UnimplementedSpringCacheServer) Delete(context.Context, *DeleteRequest) (*DeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedSpringCacheServer) GetMulti(context.Context, *GetMultiRequest) (*GetMultiResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMulti not implemented")
+}
+func (UnimplementedSpringCacheServer) SetMulti(context.Context, *SetMultiRequest) (*SetMultiResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetMulti not implemented")
+}
+func (UnimplementedSpringCacheServer) InvalidateTag(context.Context, *InvalidateTagRequest) (*InvalidateTagResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method InvalidateTag not implemented")
+}
 func (UnimplementedSpringCacheServer) mustEmbedUnimplementedSpringCacheServer() {}
 
 // UnsafeSpringCacheServer may be embedded to opt out of forward compatibility for this service.
@@ -120,6 +176,78 @@ func _SpringCache_Set_Handler(srv interface{}, ctx context.Context, dec func(int
 	return interceptor(ctx, in, info, handler)
 }
 
+func _SpringCache_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SpringCacheServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/springcachepb.SpringCache/Delete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SpringCacheServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SpringCache_GetMulti_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMultiRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SpringCacheServer).GetMulti(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/springcachepb.SpringCache/GetMulti",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SpringCacheServer).GetMulti(ctx, req.(*GetMultiRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SpringCache_SetMulti_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetMultiRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SpringCacheServer).SetMulti(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/springcachepb.SpringCache/SetMulti",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SpringCacheServer).SetMulti(ctx, req.(*SetMultiRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SpringCache_InvalidateTag_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InvalidateTagRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SpringCacheServer).InvalidateTag(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/springcachepb.SpringCache/InvalidateTag",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SpringCacheServer).InvalidateTag(ctx, req.(*InvalidateTagRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // SpringCache_ServiceDesc is the grpc.ServiceDesc for SpringCache service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -135,6 +263,22 @@ var SpringCache_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Set",
 			Handler:    _SpringCache_Set_Handler,
 		},
+		{
+			MethodName: "Delete",
+			Handler:    _SpringCache_Delete_Handler,
+		},
+		{
+			MethodName: "GetMulti",
+			Handler:    _SpringCache_GetMulti_Handler,
+		},
+		{
+			MethodName: "SetMulti",
+			Handler:    _SpringCache_SetMulti_Handler,
+		},
+		{
+			MethodName: "InvalidateTag",
+			Handler:    _SpringCache_InvalidateTag_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "springcachepb/springcachepb.proto",